@@ -0,0 +1,183 @@
+// Package cart reads the cartridges exported by Octo, the CHIP-8 development
+// environment at https://github.com/JohnEarnest/Octo.
+//
+// A cartridge is a GIF image that carries a payload next to its picture. The
+// payload is stored two bits at a time in the low bits of the palette index of
+// every pixel, from the first frame to the last, and from the most significant
+// bits of every byte to the least significant ones. It starts with its length,
+// as a 32-bit big-endian integer, followed by a JSON object with the program and
+// the options of Octo:
+//
+//	{"program": ": main ...", "options": {"tickrate": 20, "shiftQuirks": false, ...}}
+//
+// The program is Octo assembly source, not the bytes of a rom, and this
+// package doesn't assemble it. The options are mapped to the configuration of
+// the emulator: the quirks, the speed, and the colors of the display.
+package cart
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"time"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// Cartridge is the payload of a cartridge.
+type Cartridge struct {
+	Program string  `json:"program"` // Octo assembly source of the program
+	Options Options `json:"options"`
+}
+
+// Options are the options of Octo stored in a cartridge. Options that don't
+// affect the emulation, like the font or the orientation of the screen, are
+// ignored.
+type Options struct {
+	TickRate        int    `json:"tickrate"`        // Instructions executed in every frame
+	BackgroundColor string `json:"backgroundColor"` // Color of the pixels that are off
+	FillColor       string `json:"fillColor"`       // Color of the pixels in the first plane
+	FillColor2      string `json:"fillColor2"`      // Color of the pixels in the second plane
+	BlendColor      string `json:"blendColor"`      // Color of the pixels in both planes
+	ShiftQuirks     bool   `json:"shiftQuirks"`     // SHR and SHL shift Vx in place
+	LoadStoreQuirks bool   `json:"loadStoreQuirks"` // LD [I], Vx and LD Vx, [I] leave I unchanged
+	VFOrderQuirks   bool   `json:"vfOrderQuirks"`   // VF is written before the result, not supported
+	ClipQuirks      bool   `json:"clipQuirks"`      // DRW clips sprites instead of wrapping them
+	JumpQuirks      bool   `json:"jumpQuirks"`      // JP V0, addr jumps to XNN + Vx
+	LogicQuirks     bool   `json:"logicQuirks"`     // OR, AND, and XOR reset VF
+	VBlankQuirks    bool   `json:"vBlankQuirks"`    // DRW waits for the next frame
+}
+
+// Read reads a cartridge from r. It returns an error if r is not a GIF image,
+// or if the image doesn't carry a valid payload.
+func Read(r io.Reader) (Cartridge, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return Cartridge{}, fmt.Errorf("read cartridge: %v", err)
+	}
+
+	data := payload(g.Image)
+
+	if len(data) < 4 {
+		return Cartridge{}, fmt.Errorf("read cartridge: no payload")
+	}
+
+	size := binary.BigEndian.Uint32(data)
+
+	if uint64(size) > uint64(len(data)-4) {
+		return Cartridge{}, fmt.Errorf("read cartridge: truncated payload of %d bytes", size)
+	}
+
+	var c Cartridge
+
+	if err := json.Unmarshal(data[4:4+size], &c); err != nil {
+		return Cartridge{}, fmt.Errorf("read cartridge: %v", err)
+	}
+
+	return c, nil
+}
+
+// payload returns the bytes stored in the low bits of the pixels of frames.
+func payload(frames []*image.Paletted) []byte {
+	var (
+		data []byte
+		b    byte
+		n    int // Bits in b
+	)
+
+	for _, frame := range frames {
+		r := frame.Rect
+
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				b = b<<2 | frame.ColorIndexAt(x, y)&3
+				n += 2
+
+				if n == 8 {
+					data = append(data, b)
+					b, n = 0, 0
+				}
+			}
+		}
+	}
+
+	return data
+}
+
+// Quirks returns the quirks selected by the options. Octo is an XO-CHIP
+// interpreter, so behaviors without an option are the ones of
+// [emulator.VariantXOCHIP].
+func (o Options) Quirks() emulator.Quirks {
+	q := emulator.VariantXOCHIP.Quirks()
+
+	q.Shift = emulator.ShiftVIPCopyVY
+	if o.ShiftQuirks {
+		q.Shift = emulator.ShiftInPlaceVX
+	}
+
+	q.MemoryIncrementsI = !o.LoadStoreQuirks
+	q.WrapSprites = !o.ClipQuirks
+	q.JumpUsesVX = o.JumpQuirks
+	q.LogicResetsVF = o.LogicQuirks
+	q.DisplayWait = o.VBlankQuirks
+
+	return q
+}
+
+// Variant returns the variant whose quirks are the ones selected by the
+// options. It returns false if the quirks don't match any variant.
+func (o Options) Variant() (emulator.Variant, bool) {
+	q := o.Quirks()
+
+	for _, name := range emulator.VariantNames() {
+		v, _ := emulator.ParseVariant(name)
+
+		if v.Quirks() == q {
+			return v, true
+		}
+	}
+
+	return 0, false
+}
+
+// StepsPerFrame returns the instructions executed in every frame, or
+// [emulator.DefaultStepsPerFrame] if the options don't set a valid tick rate.
+func (o Options) StepsPerFrame() int {
+	if o.TickRate <= 0 {
+		return emulator.DefaultStepsPerFrame
+	}
+	return o.TickRate
+}
+
+// IPS returns the instructions executed in every second.
+func (o Options) IPS() int {
+	return o.StepsPerFrame() * int(time.Second/emulator.FramePeriod)
+}
+
+// Palette returns the colors of the pixels, indexed by the bitplanes they are
+// on. It returns an error if a color is not in the #rrggbb form used by Octo.
+func (o Options) Palette() ([emulator.AllPlanes + 1]color.RGBA, error) {
+	var palette [emulator.AllPlanes + 1]color.RGBA
+
+	for i, s := range []string{o.BackgroundColor, o.FillColor, o.FillColor2, o.BlendColor} {
+		var r, g, b uint8
+
+		if n, err := fmt.Sscanf(s, "#%02x%02x%02x", &r, &g, &b); err != nil || n != 3 || len(s) != 7 {
+			return palette, fmt.Errorf("invalid color %q", s)
+		}
+
+		palette[i] = color.RGBA{R: r, G: g, B: b, A: 0xff}
+	}
+
+	return palette, nil
+}
+
+// Apply configures e with the quirks and the speed selected by the options.
+func (o Options) Apply(e *emulator.Emulator) error {
+	e.SetQuirks(o.Quirks())
+	return e.SetStepsPerFrame(o.StepsPerFrame())
+}
@@ -0,0 +1,187 @@
+package cart_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/francescomari/chip-8/cart"
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// buildCartridge returns a GIF image carrying payload like an Octo cartridge,
+// split over two frames.
+func buildCartridge(t *testing.T, payload string) []byte {
+	t.Helper()
+
+	data := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	data = append(data, payload...)
+
+	return buildImage(t, data)
+}
+
+// buildImage returns a GIF image storing data in the low bits of its pixels.
+func buildImage(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	// Every palette entry is repeated four times, so that the low bits of the
+	// index don't change the picture.
+
+	var palette color.Palette
+
+	for _, c := range []color.Color{color.Black, color.White} {
+		for range 4 {
+			palette = append(palette, c)
+		}
+	}
+
+	var g gif.GIF
+
+	pixels := 4 * len(data)
+	width := 16
+	height := (pixels/width+1)/2 + 1
+
+	for i := 0; i < 2*width*height; i += width * height {
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+
+		for j := range frame.Pix {
+			if k := i + j; k < pixels {
+				frame.Pix[j] = data[k/4] >> (6 - 2*(k%4)) & 3
+			}
+			if j%3 == 0 {
+				frame.Pix[j] |= 4
+			}
+		}
+
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 0)
+	}
+
+	var b bytes.Buffer
+
+	if err := gif.EncodeAll(&b, &g); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	return b.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	payload := `{
+		"program": ": main\n\tloop again\n",
+		"options": {
+			"tickrate": 7,
+			"backgroundColor": "#000000",
+			"fillColor": "#FF0000",
+			"fillColor2": "#00ff00",
+			"blendColor": "#0000FF",
+			"shiftQuirks": true,
+			"loadStoreQuirks": true,
+			"vfOrderQuirks": false,
+			"clipQuirks": true,
+			"jumpQuirks": true,
+			"logicQuirks": false,
+			"vBlankQuirks": false,
+			"fontStyle": "octo"
+		}
+	}`
+
+	c, err := cart.Read(bytes.NewReader(buildCartridge(t, payload)))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if want := ": main\n\tloop again\n"; c.Program != want {
+		t.Fatalf("program: got %q, want %q", c.Program, want)
+	}
+
+	// The quirks are the ones of SUPER-CHIP.
+
+	if got, want := c.Options.Quirks(), emulator.VariantSCHIP.Quirks(); got != want {
+		t.Fatalf("quirks: got %+v, want %+v", got, want)
+	}
+
+	if v, ok := c.Options.Variant(); !ok || v != emulator.VariantSCHIP {
+		t.Fatalf("variant: got %v, %v", v, ok)
+	}
+
+	if got := c.Options.StepsPerFrame(); got != 7 {
+		t.Fatalf("steps per frame: got %d, want 7", got)
+	}
+
+	if got := c.Options.IPS(); got != 420 {
+		t.Fatalf("ips: got %d, want 420", got)
+	}
+
+	palette, err := c.Options.Palette()
+	if err != nil {
+		t.Fatalf("palette: %v", err)
+	}
+
+	want := [emulator.AllPlanes + 1]color.RGBA{
+		{R: 0x00, G: 0x00, B: 0x00, A: 0xff},
+		{R: 0xff, G: 0x00, B: 0x00, A: 0xff},
+		{R: 0x00, G: 0xff, B: 0x00, A: 0xff},
+		{R: 0x00, G: 0x00, B: 0xff, A: 0xff},
+	}
+
+	if palette != want {
+		t.Fatalf("palette: got %v, want %v", palette, want)
+	}
+}
+
+func TestOptionsApply(t *testing.T) {
+	o := cart.Options{TickRate: 30, VBlankQuirks: true}
+
+	e := emulator.New()
+
+	if err := o.Apply(e); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	if got, want := e.Quirks(), o.Quirks(); got != want {
+		t.Fatalf("quirks: got %+v, want %+v", got, want)
+	}
+
+	if _, ok := o.Variant(); ok {
+		t.Fatal("variant: expected no match")
+	}
+}
+
+func TestOptionsDefaults(t *testing.T) {
+	var o cart.Options
+
+	if got, want := o.StepsPerFrame(), emulator.DefaultStepsPerFrame; got != want {
+		t.Fatalf("steps per frame: got %d, want %d", got, want)
+	}
+
+	if v, ok := o.Variant(); !ok || v != emulator.VariantXOCHIP {
+		t.Fatalf("variant: got %v, %v", v, ok)
+	}
+
+	if _, err := o.Palette(); err == nil {
+		t.Fatal("palette: expected error for missing colors")
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not a gif", []byte("GIF? no")},
+		{"not json", buildCartridge(t, `program`)},
+		{"oversized", buildImage(t, []byte{0x00, 0x00, 0x10, 0x00, '{', '}'})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cart.Read(bytes.NewReader(tt.data)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "github.com/francescomari/chip-8/emulator"
+
+// sampleRate is the sample rate of the audio context.
+const sampleRate = 44100
+
+// patternFrames is the length of the sound rendered from an audio pattern, the
+// same as the beep.
+const patternFrames = 5120
+
+// patternVolume is the amplitude of the samples rendered from an audio pattern.
+const patternVolume = 0x2000
+
+// renderPattern renders frames of the audio pattern, played at rate samples per
+// second, as 16-bit stereo samples at sampleRate, the format of the audio
+// context. The pattern is played from the most significant bit of its first
+// byte, and loops.
+func renderPattern(pattern emulator.AudioPattern, rate float64, frames int) []byte {
+	b := make([]byte, 0, frames*4)
+
+	for i := range frames {
+		bit := int(float64(i)*rate/sampleRate) % (len(pattern) * 8)
+
+		sample := int16(-patternVolume)
+
+		if pattern[bit/8]&(0x80>>(bit%8)) != 0 {
+			sample = patternVolume
+		}
+
+		b = append(b, byte(sample), byte(sample>>8), byte(sample), byte(sample>>8))
+	}
+
+	return b
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestRenderPattern(t *testing.T) {
+	pattern := emulator.AudioPattern{0x80}
+
+	// Play a bit every 4 frames, so that the first bit lasts 4 frames and the
+	// pattern loops after 512.
+
+	b := renderPattern(pattern, sampleRate/4, 520)
+
+	if len(b) != 520*4 {
+		t.Fatalf("length: got %d, want %d", len(b), 520*4)
+	}
+
+	for i := range 520 {
+		left := int16(binary.LittleEndian.Uint16(b[i*4:]))
+		right := int16(binary.LittleEndian.Uint16(b[i*4+2:]))
+
+		want := int16(-patternVolume)
+
+		if i%512 < 4 {
+			want = patternVolume
+		}
+
+		if left != want || right != want {
+			t.Fatalf("frame %d: got %d and %d, want %d", i, left, right, want)
+		}
+	}
+}
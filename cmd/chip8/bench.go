@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// benchmarkDuration is how long the benchmark mode runs a rom.
+const benchmarkDuration = 5 * time.Second
+
+// benchmarkResult reports how fast the emulator ran a rom.
+type benchmarkResult struct {
+	elapsed      time.Duration
+	frames       uint64
+	instructions uint64
+	draws        uint64
+	allocs       uint64 // Heap objects allocated
+	bytes        uint64 // Heap bytes allocated
+}
+
+// benchmark runs the program loaded in e as fast as possible for the duration d,
+// without rendering it. The instructions are spread over the frames by budget,
+// like when the rom runs in a window, so the timers are advanced at the same
+// rate. If the program halts, it is reset and started again.
+func benchmark(e *emulator.Emulator, budget instructionBudget, d time.Duration) (benchmarkResult, error) {
+	var (
+		result benchmarkResult
+		state  emulator.State
+		before runtime.MemStats
+		after  runtime.MemStats
+	)
+
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+
+	for time.Since(start) < d {
+
+		// Checking the time after every frame, rather than after every
+		// instruction, keeps the measurement out of the results.
+
+		for range budget.steps(1) {
+			ok, err := e.Step()
+			if err != nil {
+				return result, err
+			}
+
+			result.instructions++
+
+			if !ok {
+				e.Reset()
+			}
+		}
+
+		// Copying the whole state, with its 64KB of memory, would be measured
+		// too, so only the registers are copied.
+
+		e.CPUState(&state)
+		result.draws += uint64(state.DrawsThisFrame)
+		result.frames++
+		e.Clock()
+	}
+
+	result.elapsed = time.Since(start)
+
+	runtime.ReadMemStats(&after)
+
+	result.allocs = after.Mallocs - before.Mallocs
+	result.bytes = after.TotalAlloc - before.TotalAlloc
+
+	return result, nil
+}
+
+// printBenchmark writes the results of a benchmark, with the instructions and
+// the draws per second.
+func printBenchmark(w io.Writer, r benchmarkResult) {
+	perSecond := func(n uint64) float64 {
+		return float64(n) / r.elapsed.Seconds()
+	}
+
+	fmt.Fprintf(w, "duration: %v\n", r.elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "instructions: %d (%.0f/s)\n", r.instructions, perSecond(r.instructions))
+	fmt.Fprintf(w, "draws: %d (%.0f/s)\n", r.draws, perSecond(r.draws))
+	fmt.Fprintf(w, "allocations: %d (%d bytes)\n", r.allocs, r.bytes)
+}
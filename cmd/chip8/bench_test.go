@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestBenchmark(t *testing.T) {
+	rom, err := os.ReadFile("../../roms/2-ibm-logo.ch8")
+	if err != nil {
+		t.Fatalf("read rom: %v", err)
+	}
+
+	e := emulator.New()
+
+	if err := e.Load(rom); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	budget := instructionBudget{ips: framesPerSecond * emulator.DefaultStepsPerFrame}
+
+	r, err := benchmark(e, budget, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("benchmark: %v", err)
+	}
+
+	if r.elapsed < 20*time.Millisecond {
+		t.Errorf("elapsed: got %v, want at least 20ms", r.elapsed)
+	}
+
+	// The logo is made of six sprites, drawn at the beginning of the rom, which
+	// then loops forever.
+
+	if r.draws != 6 {
+		t.Errorf("draws: got %d, want 6", r.draws)
+	}
+
+	if r.instructions < 1000 || r.instructions%emulator.DefaultStepsPerFrame != 0 {
+		t.Errorf("instructions: got %d", r.instructions)
+	}
+
+	var b strings.Builder
+
+	printBenchmark(&b, r)
+
+	if !strings.HasPrefix(b.String(), "duration: ") || strings.Count(b.String(), "\n") != 4 {
+		t.Errorf("summary:\n%s", b.String())
+	}
+}
+
+func TestBenchmarkSpeed(t *testing.T) {
+	rom, err := os.ReadFile("../../roms/2-ibm-logo.ch8")
+	if err != nil {
+		t.Fatalf("read rom: %v", err)
+	}
+
+	e := emulator.New()
+
+	if err := e.Load(rom); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// 90 instructions per second are one and a half instructions per frame.
+
+	budget, err := newInstructionBudget(90)
+	if err != nil {
+		t.Fatalf("new budget: %v", err)
+	}
+
+	r, err := benchmark(e, budget, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("benchmark: %v", err)
+	}
+
+	if want := r.frames * 90 / uint64(framesPerSecond); r.frames == 0 || r.instructions != want {
+		t.Errorf("instructions: got %d in %d frames, want %d", r.instructions, r.frames, want)
+	}
+}
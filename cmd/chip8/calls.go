@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/francescomari/chip-8/debug"
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// calls implements the calls command, which prints the static call graph of a
+// rom.
+func calls(args []string, w io.Writer) error {
+	var dot bool
+
+	fs := flag.NewFlagSet("calls", flag.ExitOnError)
+	fs.BoolVar(&dot, "dot", false, "Print the call graph in the DOT language of Graphviz")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chip8 calls [-dot] ROM\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("invalid number of arguments")
+	}
+
+	rom, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("read file: %v", err)
+	}
+
+	graph := debug.CallGraph(rom, emulator.ProgramStart)
+
+	if dot {
+		debug.PrintCallGraphDOT(w, graph)
+	} else {
+		debug.PrintCallGraph(w, graph)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// comparison runs the same rom on two emulators with different quirks, feeding
+// both the same input, so that the effect of the quirks can be observed side by
+// side.
+type comparison struct {
+	sides  [2]*comparisonSide
+	events []emulator.KeyEvent // Key events queued for the next frame
+}
+
+// comparisonSide is one of the emulators of a comparison.
+type comparisonSide struct {
+	label    string // Quirks of the emulator, shown over its display
+	emulator *emulator.Emulator
+	halted   bool
+}
+
+// newComparison loads the rom in two emulators with the left and right quirks.
+// Both run stepsPerFrame instructions in every frame, and share the seed of the
+// random number generator, so that they only diverge because of the quirks.
+func newComparison(rom []byte, stepsPerFrame int, left, right emulator.Quirks) (*comparison, error) {
+	var c comparison
+
+	seed := rand.Uint64()
+
+	for i, q := range []emulator.Quirks{left, right} {
+		e := emulator.New()
+
+		e.SetQuirks(q)
+		e.SetRNG(rand.New(rand.NewPCG(seed, seed)).Uint32)
+
+		if err := e.SetStepsPerFrame(stepsPerFrame); err != nil {
+			return nil, err
+		}
+
+		if err := e.Load(rom); err != nil {
+			return nil, fmt.Errorf("load: %w", err)
+		}
+
+		c.sides[i] = &comparisonSide{
+			label:    strings.Join(q.Names(), ","),
+			emulator: e,
+		}
+	}
+
+	return &c, nil
+}
+
+// queueKey queues a key event for both emulators. The event is delivered at the
+// start of the next frame.
+func (c *comparison) queueKey(down bool, key uint8) {
+	c.events = append(c.events, emulator.KeyEvent{Down: down, Key: key})
+}
+
+// runFrame runs one frame on both emulators, in lockstep mode, with the key
+// events queued since the previous frame. An emulator whose program halted
+// stays halted, while the other one keeps running.
+func (c *comparison) runFrame() error {
+	var state emulator.State
+
+	for i, s := range c.sides {
+		if s.halted {
+			continue
+		}
+
+		s.emulator.State(&state)
+
+		ok, err := s.emulator.StepFrameWithInput(state.FrameCount, c.events)
+		if err != nil {
+			return fmt.Errorf("side %d: %v", i+1, err)
+		}
+
+		s.halted = !ok
+	}
+
+	c.events = c.events[:0]
+
+	return nil
+}
+
+// diverged returns true if the state of the two emulators differs.
+func (c *comparison) diverged() bool {
+	return c.sides[0].emulator.StateHash() != c.sides[1].emulator.StateHash()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestComparisonShiftQuirk(t *testing.T) {
+	rom := []byte{
+		0x60, 0x81, // LD V0, 0x81
+		0x61, 0x06, // LD V1, 0x06
+		0x80, 0x16, // SHR V0, V1
+		0x80, 0x1e, // SHL V0, V1
+		0x80, 0x16, // SHR V0, V1
+		0x12, 0x0a, // JP 0x20a
+	}
+
+	left, right := emulator.DefaultQuirks(), emulator.DefaultQuirks()
+	left.Shift = emulator.ShiftVIPCopyVY
+	right.Shift = emulator.ShiftInPlaceVX
+
+	c, err := newComparison(rom, emulator.DefaultStepsPerFrame, left, right)
+	if err != nil {
+		t.Fatalf("new comparison: %v", err)
+	}
+
+	if err := c.runFrame(); err != nil {
+		t.Fatalf("run frame: %v", err)
+	}
+
+	if !c.diverged() {
+		t.Fatalf("emulators didn't diverge")
+	}
+
+	var l, r emulator.State
+
+	c.sides[0].emulator.State(&l)
+	c.sides[1].emulator.State(&r)
+
+	// Shifting V1 into V0 always gives 3, while shifting V0 in place gives
+	// 0x81 >> 1 << 1 >> 1.
+
+	if l.V[0] != 0x03 || r.V[0] != 0x40 {
+		t.Errorf("v0: got %02x and %02x, want 03 and 40", l.V[0], r.V[0])
+	}
+
+	if c.sides[0].label == c.sides[1].label {
+		t.Errorf("labels: both %q", c.sides[0].label)
+	}
+}
+
+func TestComparisonSameQuirks(t *testing.T) {
+	rom := []byte{
+		0xc0, 0xff, // RND V0, 0xff
+		0xf0, 0x0a, // LD V0, K
+		0x12, 0x00, // JP 0x200
+	}
+
+	q := emulator.DefaultQuirks()
+
+	c, err := newComparison(rom, emulator.DefaultStepsPerFrame, q, q)
+	if err != nil {
+		t.Fatalf("new comparison: %v", err)
+	}
+
+	// Random numbers and keys are the same on both sides, so the emulators
+	// never diverge.
+
+	for frame := range 10 {
+		if frame%2 == 0 {
+			c.queueKey(frame%4 == 0, 0x5)
+		}
+
+		if err := c.runFrame(); err != nil {
+			t.Fatalf("run frame: %v", err)
+		}
+
+		if c.diverged() {
+			t.Fatalf("frame %d: emulators diverged", frame)
+		}
+	}
+}
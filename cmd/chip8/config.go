@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// config is the configuration of the emulator for a rom. It is stored in a
+// sidecar file next to the rom, so that settings found in the debugger can be
+// reused and shared.
+type config struct {
+	Variant       string   `json:"variant,omitempty"` // Name of the variant, as accepted by -variant
+	Quirks        []string `json:"quirks"`            // Quirks applied on top of the variant
+	StepsPerFrame int      `json:"stepsPerFrame"`     // Instructions executed in every frame
+}
+
+// sidecarPath returns the path of the configuration file of the rom at path.
+func sidecarPath(path string) string {
+	return path + ".json"
+}
+
+func newConfig(variant string, quirks emulator.Quirks, stepsPerFrame int) config {
+	return config{
+		Variant:       variant,
+		Quirks:        quirks.Names(),
+		StepsPerFrame: stepsPerFrame,
+	}
+}
+
+// quirks returns the quirks of the variant, changed by the quirks listed in c.
+func (c config) quirks() (emulator.Quirks, error) {
+	base := emulator.DefaultQuirks()
+
+	if c.Variant != "" {
+		v, err := emulator.ParseVariant(c.Variant)
+		if err != nil {
+			return base, err
+		}
+		base = v.Quirks()
+	}
+
+	return emulator.ParseQuirks(strings.Join(c.Quirks, ","), base)
+}
+
+func writeConfig(path string, c config) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func readConfig(path string) (config, error) {
+	var c config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("parse %s: %v", path, err)
+	}
+
+	if c.StepsPerFrame <= 0 {
+		return c, fmt.Errorf("parse %s: invalid steps per frame %d", path, c.StepsPerFrame)
+	}
+
+	return c, nil
+}
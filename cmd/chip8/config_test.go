@@ -0,0 +1,45 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestConfigRoundTrip(t *testing.T) {
+	quirks := emulator.VariantSCHIP.Quirks()
+	quirks.DisplayWait = true
+
+	path := filepath.Join(t.TempDir(), "game.ch8.json")
+
+	if err := writeConfig(path, newConfig("schip", quirks, 12)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	c, err := readConfig(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if c.Variant != "schip" {
+		t.Errorf("variant: got %q, want schip", c.Variant)
+	}
+	if c.StepsPerFrame != 12 {
+		t.Errorf("steps per frame: got %d, want 12", c.StepsPerFrame)
+	}
+
+	got, err := c.quirks()
+	if err != nil {
+		t.Fatalf("quirks: %v", err)
+	}
+	if got != quirks {
+		t.Errorf("quirks: got %+v, want %+v", got, quirks)
+	}
+}
+
+func TestSidecarPath(t *testing.T) {
+	if got := sidecarPath("roms/game.ch8"); got != "roms/game.ch8.json" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/francescomari/chip-8/debug"
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// disasm implements the disasm command, which prints the instructions of a rom.
+func disasm(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("disasm", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chip8 disasm ROM\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("invalid number of arguments")
+	}
+
+	rom, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("read file: %v", err)
+	}
+
+	printDisassembly(w, rom)
+
+	return nil
+}
+
+// printDisassembly writes one line for every pair of bytes in rom, decoded as an
+// instruction. LD I, long is printed on a single line together with the address
+// that follows it. The disassembly is linear, so sprites and other data embedded
+// in the rom are decoded as instructions too. A trailing odd byte is printed as
+// data.
+func printDisassembly(w io.Writer, rom []byte) {
+	for i := 0; i < len(rom); i += 2 {
+		addr := emulator.ProgramStart + i
+
+		if i+1 == len(rom) {
+			fmt.Fprintf(w, "%04x: %02x\n", addr, rom[i])
+			break
+		}
+
+		op := uint16(rom[i])<<8 | uint16(rom[i+1])
+
+		if op == emulator.OpTypeMisc|emulator.OpLDIL && i+3 < len(rom) {
+			nnnn := uint16(rom[i+2])<<8 | uint16(rom[i+3])
+			fmt.Fprintf(w, "%04x: %04x %04x  %v %04x\n", addr, op, nnnn, debug.Instruction(op), nnnn)
+			i += 2
+			continue
+		}
+
+		fmt.Fprintf(w, "%04x: %04x  %v\n", addr, op, debug.Instruction(op))
+	}
+}
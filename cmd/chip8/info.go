@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/sha1"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// info implements the info command, which describes a rom without running it.
+func info(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chip8 info ROM\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("invalid number of arguments")
+	}
+
+	rom, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("read file: %v", err)
+	}
+
+	printInfo(w, rom)
+
+	return nil
+}
+
+func printInfo(w io.Writer, rom []byte) {
+	features := detectFeatures(rom)
+
+	fmt.Fprintf(w, "size: %d bytes\n", len(rom))
+	fmt.Fprintf(w, "sha1: %x\n", sha1.Sum(rom))
+	fmt.Fprintf(w, "families:")
+
+	for family, used := range features.families {
+		if used {
+			fmt.Fprintf(w, " %x", family)
+		}
+	}
+
+	fmt.Fprintf(w, "\n")
+
+	if len(features.extensions) > 0 {
+		fmt.Fprintf(w, "extensions: %s\n", strings.Join(features.extensions, ", "))
+	} else {
+		fmt.Fprintf(w, "extensions: none\n")
+	}
+}
+
+// romFeatures describes the instructions found by decoding a rom.
+type romFeatures struct {
+	families   [16]bool // Opcode families, indexed by the most significant nibble
+	extensions []string // Instruction sets beyond CHIP-8, like schip and xochip
+}
+
+// detectFeatures decodes the instructions of rom reachable from its first
+// instruction, following jumps, calls, and both outcomes of every skip. The
+// target of JP V0, addr depends on a register, so the instructions reached only
+// through it are not detected.
+func detectFeatures(rom []byte) romFeatures {
+	var (
+		features romFeatures
+		schip    bool
+		xochip   bool
+		visited  = make([]bool, len(rom))
+		pending  = []int{0}
+	)
+
+	for len(pending) > 0 {
+		i := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if i < 0 || i+1 >= len(rom) || visited[i] {
+			continue
+		}
+
+		visited[i] = true
+
+		op := uint16(rom[i])<<8 | uint16(rom[i+1])
+
+		features.families[op>>12] = true
+
+		schip = schip || isSCHIP(op)
+		xochip = xochip || isXOCHIP(op)
+
+		pending = append(pending, successors(i, op)...)
+	}
+
+	if schip {
+		features.extensions = append(features.extensions, "schip")
+	}
+	if xochip {
+		features.extensions = append(features.extensions, "xochip")
+	}
+
+	return features
+}
+
+// successors returns the offsets in the rom of the instructions that can be
+// executed after op, which is at offset i.
+func successors(i int, op uint16) []int {
+	target := int(op&0x0fff) - emulator.ProgramStart
+
+	switch op >> 12 {
+	case 0x0:
+		switch {
+		case op == 0x00e0, op&0xfff0 == 0x00c0, op&0xfff0 == 0x00d0, op >= 0x00fb && op <= 0x00ff && op != 0x00fd:
+			return []int{i + 2}
+		}
+		// RET, EXIT, and the other machine code routines end the flow.
+		return nil
+	case 0x1:
+		return []int{target}
+	case 0x2:
+		return []int{target, i + 2}
+	case 0x3, 0x4, 0x5, 0x9, 0xe:
+		return []int{i + 2, i + 4}
+	case 0xb:
+		return nil
+	case 0xf:
+		if op == 0xf000 {
+			return []int{i + 4}
+		}
+	}
+
+	return []int{i + 2}
+}
+
+// isSCHIP returns true if op is only defined by SUPER-CHIP: scroll down, scroll
+// right and left, exit, low and high resolution, 16×16 sprites, the big font,
+// and the RPL flags.
+func isSCHIP(op uint16) bool {
+	switch {
+	case op&0xfff0 == 0x00c0:
+		return true
+	case op >= 0x00fb && op <= 0x00ff:
+		return true
+	case op&0xf00f == 0xd000:
+		return true
+	}
+
+	switch op & 0xf0ff {
+	case 0xf030, 0xf075, 0xf085:
+		return true
+	}
+
+	return false
+}
+
+// isXOCHIP returns true if op is only defined by XO-CHIP: scroll up, saving and
+// loading register ranges, long addressing, the audio pattern, plane selection,
+// and the pitch register.
+func isXOCHIP(op uint16) bool {
+	switch {
+	case op&0xfff0 == 0x00d0:
+		return true
+	case op&0xf00f == 0x5002, op&0xf00f == 0x5003:
+		return true
+	case op == 0xf000, op == 0xf002:
+		return true
+	}
+
+	switch op & 0xf0ff {
+	case 0xf001, 0xf03a:
+		return true
+	}
+
+	return false
+}
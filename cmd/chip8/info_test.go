@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestInfo(t *testing.T) {
+	var b strings.Builder
+
+	if err := info([]string{"../../roms/2-ibm-logo.ch8"}, &b); err != nil {
+		t.Fatalf("info: %v", err)
+	}
+
+	want := "size: 132 bytes\n" +
+		"sha1: b9bbc12cee3f7b9d3b1f69161f7d7a2d86953379\n" +
+		"families: 0 1 6 7 a d\n" +
+		"extensions: none\n"
+
+	if got := b.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDetectFeatures(t *testing.T) {
+	features := detectFeatures([]byte{
+		0x00, 0xe0, // CLS
+		0x00, 0xc4, // SCD 0x4
+		0x12, 0x00, // JP 0x200
+	})
+
+	for family, used := range features.families {
+		if want := family == 0x0 || family == 0x1; used != want {
+			t.Errorf("family %x: got %v, want %v", family, used, want)
+		}
+	}
+
+	if len(features.extensions) != 1 || features.extensions[0] != "schip" {
+		t.Fatalf("extensions: got %v, want [schip]", features.extensions)
+	}
+}
+
+func TestDisassembly(t *testing.T) {
+	rom, err := os.ReadFile("../../roms/2-ibm-logo.ch8")
+	if err != nil {
+		t.Fatalf("read rom: %v", err)
+	}
+
+	var b strings.Builder
+
+	printDisassembly(&b, rom[:5])
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), b.String())
+	}
+	if !strings.HasPrefix(lines[0], "0200: 00e0") {
+		t.Fatalf("first line: %q", lines[0])
+	}
+	if lines[2] != "0204: 60" {
+		t.Fatalf("trailing byte: %q", lines[2])
+	}
+}
+
+func TestDisassemblyLoadIndexLong(t *testing.T) {
+	var b strings.Builder
+
+	printDisassembly(&b, []byte{
+		0xf0, 0x00, 0x22, 0x0a, // LD I, long 0x220a
+		0x00, 0xe0, // CLS
+	})
+
+	want := "0200: f000 220a  ld i, long 220a\n" +
+		"0204: 00e0  cls\n"
+
+	if b.String() != want {
+		t.Fatalf("got %q, want %q", b.String(), want)
+	}
+}
+
+func TestCalls(t *testing.T) {
+	var b strings.Builder
+
+	if err := calls([]string{"../../roms/2-ibm-logo.ch8"}, &b); err != nil {
+		t.Fatalf("calls: %v", err)
+	}
+
+	if want := "0200:\n"; b.String() != want {
+		t.Fatalf("got %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/francescomari/chip-8/debug"
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// logLevel controls which messages are written by a logger. Every level
+// includes the messages of the levels before it.
+type logLevel int
+
+const (
+	logQuiet logLevel = iota // Only fatal errors
+	logInfo                  // Notable events, like quirk changes
+	logDebug                 // Machine state after every debugger action
+)
+
+var logLevels = []string{
+	logQuiet: "quiet",
+	logInfo:  "info",
+	logDebug: "debug",
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	for level, name := range logLevels {
+		if name == s {
+			return logLevel(level), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown log level %q (valid levels: %s)", s, strings.Join(logLevels, ", "))
+}
+
+type logger struct {
+	level logLevel
+	out   *log.Logger
+}
+
+func newLogger(w io.Writer, level logLevel) *logger {
+	return &logger{
+		level: level,
+		out:   log.New(w, "", log.LstdFlags),
+	}
+}
+
+func (l *logger) infof(format string, args ...any) {
+	if l.level >= logInfo {
+		l.out.Printf(format, args...)
+	}
+}
+
+func (l *logger) debugf(format string, args ...any) {
+	if l.level >= logDebug {
+		l.out.Printf(format, args...)
+	}
+}
+
+// dumpState writes the instruction and the registers from state at the debug
+// level.
+func (l *logger) dumpState(state *emulator.State) {
+	if l.level < logDebug {
+		return
+	}
+
+	var w strings.Builder
+
+	debug.PrintInstruction(&w, state)
+	w.WriteString(": ")
+	debug.PrintRegisters(&w, state)
+	w.WriteString(", ")
+	debug.PrintState(&w, state)
+
+	l.out.Print(w.String())
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	for _, name := range logLevels {
+		if _, err := parseLogLevel(name); err != nil {
+			t.Errorf("parse %q: %v", name, err)
+		}
+	}
+
+	if _, err := parseLogLevel("verbose"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestLoggerQuiet(t *testing.T) {
+	var b strings.Builder
+
+	l := newLogger(&b, logQuiet)
+
+	var state emulator.State
+
+	l.infof("info")
+	l.debugf("debug")
+	l.dumpState(&state)
+
+	if b.Len() != 0 {
+		t.Fatalf("expected no output, got %q", b.String())
+	}
+}
+
+func TestLoggerDebug(t *testing.T) {
+	var b strings.Builder
+
+	l := newLogger(&b, logDebug)
+
+	var state emulator.State
+	state.V[0] = 0x12
+
+	l.dumpState(&state)
+
+	if got := b.String(); !strings.Contains(got, "v0 = 12") {
+		t.Fatalf("expected registers in output, got %q", got)
+	}
+}
@@ -2,48 +2,59 @@ package main
 
 import (
 	_ "embed"
+	"errors"
 	"flag"
 	"fmt"
+	"image"
 	"image/color"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/audio"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 
 	"github.com/francescomari/chip-8/debug"
 	"github.com/francescomari/chip-8/emulator"
-)
-
-const (
-	displayScale  = 10
-	displayWidth  = displayScale * emulator.DisplayWidth
-	displayHeight = displayScale * emulator.DisplayHeight
+	"github.com/francescomari/chip-8/trace"
 )
 
 const (
 	debugCharacterWidth  = 6
 	debugCharacterHeight = 16
 	debugColumns         = 60
-	debugRows            = 14
+	debugRows            = 26
 	debugPanelScale      = 2
 	debugPanelWidth      = debugPanelScale * debugColumns * debugCharacterWidth
 	debugPanelHeight     = debugPanelScale * debugRows * debugCharacterHeight
 )
 
+// historyRows is the number of recent instructions shown in the debug panel.
+const historyRows = 3
+
+// maxUpdateFrames is the maximum number of frames run by a single update.
+const maxUpdateFrames = 4
+
+// batchSteps is the number of instructions executed at once in debug mode when
+// stepping with the Shift key pressed.
+const batchSteps = 10
+
 const (
-	regularWindowWidth  = displayWidth
-	regularWindowHeight = displayHeight
-	debugWindowWidth    = displayWidth
-	debugWindowHeight   = displayHeight + debugPanelHeight
+	memoryPanelWidth  = (memoryPrefix + 3*memoryColumns - 1) * debugCharacterWidth
+	memoryPanelHeight = memoryRows * debugCharacterHeight
 )
 
 //go:embed beep.wav
 var beep []byte
 
+// mappings maps the keys of the keyboard to the keys of the keypad. Ebitengine
+// identifies keys by their position on a US keyboard, so the keypad is in the
+// same spot on every layout.
 var mappings = map[ebiten.Key]uint8{
 	ebiten.Key1: 0x1,
 	ebiten.Key2: 0x2,
@@ -63,13 +74,58 @@ var mappings = map[ebiten.Key]uint8{
 	ebiten.KeyV: 0xf,
 }
 
+// quirkToggles lists the behaviors that can be toggled in debug mode, one for
+// every field of the quirks.
+var quirkToggles = newQuirkToggles()
+
+// quirkToggleKeys are the keys toggling the quirks, in the order of
+// quirkToggles. F11 toggles the fullscreen mode, so it is skipped.
+var quirkToggleKeys = []ebiten.Key{
+	ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF4, ebiten.KeyF5, ebiten.KeyF6,
+	ebiten.KeyF7, ebiten.KeyF8, ebiten.KeyF9, ebiten.KeyF10, ebiten.KeyF12,
+}
+
+func init() {
+	if len(quirkToggles) > len(quirkToggleKeys) {
+		panic(fmt.Sprintf("%d quirks to toggle, but only %d keys", len(quirkToggles), len(quirkToggleKeys)))
+	}
+}
+
 type Game struct {
-	emulator   *emulator.Emulator
-	debug      bool
-	halted     bool
-	state      emulator.State
-	display    *ebiten.Image
-	debugPanel *ebiten.Image
+	emulator    *emulator.Emulator
+	log         *logger
+	debug       bool
+	halted      bool
+	noTimers    bool
+	noCPU       bool
+	breaks      []*debug.Breakpoint
+	scale       int
+	aspect      aspect
+	state       emulator.State
+	width       int    // Width of the display the window was sized for
+	height      int    // Height of the display the window was sized for
+	pixels      []byte // Packed display, reused to check the reference
+	display     *ebiten.Image
+	debugPanel  *ebiten.Image
+	memory      memoryView
+	memoryPanel *ebiten.Image
+
+	stepsPerFrame int               // Instructions executed in every frame
+	budget        instructionBudget // Instructions executed in every second
+	variant       string            // Name of the variant, saved in the config file
+	configPath    string            // Path of the config file, or empty to disable saving
+
+	selfMod   *debug.SelfModTracer // Reports self-modified instructions, if set
+	reference *trace.Reference     // Frames the display is compared with, if set
+
+	lastUpdate time.Time // Time of the last update outside of debug mode
+
+	rom   string // Name of the rom, shown in the title of the window
+	title string // Title of the window, updated when the state changes
+
+	watches      []watch // Registers pinned to the watch panel
+	watchVisible bool
+	watchPanel   *ebiten.Image
 }
 
 func NewGame(e *emulator.Emulator) (*Game, error) {
@@ -77,29 +133,132 @@ func NewGame(e *emulator.Emulator) (*Game, error) {
 		return nil, fmt.Errorf("no emulator provided")
 	}
 
-	return &Game{
-		emulator:   e,
-		display:    ebiten.NewImage(emulator.DisplayWidth, emulator.DisplayHeight),
-		debugPanel: ebiten.NewImage(debugPanelWidth, debugPanelHeight),
-	}, nil
+	g := Game{
+		emulator:      e,
+		log:           newLogger(os.Stderr, logInfo),
+		scale:         defaultScale,
+		stepsPerFrame: emulator.DefaultStepsPerFrame,
+		budget:        instructionBudget{ips: framesPerSecond * emulator.DefaultStepsPerFrame},
+		pixels:        make([]byte, emulator.MaxDisplayWidth*emulator.MaxDisplayHeight/8),
+		display:       ebiten.NewImage(emulator.MaxDisplayWidth, emulator.MaxDisplayHeight),
+		debugPanel:    ebiten.NewImage(debugPanelWidth, debugPanelHeight),
+		memoryPanel:   ebiten.NewImage(memoryPanelWidth, memoryPanelHeight),
+	}
+
+	e.State(&g.state)
+
+	return &g, nil
+}
+
+func (g *Game) SetBreakpoints(breaks []*debug.Breakpoint) {
+	g.breaks = breaks
+}
+
+// SetSelfModTracer sets the tracer used to log the instructions that are
+// executed after the program overwrote them. Pass nil to stop logging them.
+func (g *Game) SetSelfModTracer(t *debug.SelfModTracer) {
+	g.selfMod = t
+}
+
+// SetWatches pins registers to the watch panel, which is shown over the display
+// in debug mode.
+func (g *Game) SetWatches(watches []watch) {
+	g.watches = watches
+	g.watchVisible = len(watches) > 0
+	g.watchPanel = ebiten.NewImage(watchWidth*debugCharacterWidth, max(1, len(watches))*debugCharacterHeight)
+}
+
+// SetReference sets the frames of a reference interpreter. While the rom runs
+// outside of debug mode, the display is compared with the reference at the end
+// of every frame, and the first difference is logged.
+func (g *Game) SetReference(r *trace.Reference) {
+	g.reference = r
+}
+
+// SetROMName sets the name of the rom shown in the title of the window.
+func (g *Game) SetROMName(name string) {
+	g.rom = name
+}
+
+func (g *Game) SetLogger(l *logger) {
+	g.log = l
+}
+
+// SetScale sets the width, in pixels of the window, of a pixel of the display,
+// and the aspect of the display. It returns an error if the window wouldn't fit
+// the largest resolution.
+func (g *Game) SetScale(scale int, a aspect) error {
+	if _, _, err := windowSize(scale, emulator.MaxDisplayWidth, emulator.MaxDisplayHeight, a); err != nil {
+		return err
+	}
+	g.scale = scale
+	g.aspect = a
+	g.adjustWindowSize()
+	return nil
+}
+
+// SetConfig sets the path where the configuration is saved in debug mode, the
+// name of the variant it refers to, and the number of instructions executed in
+// every frame.
+func (g *Game) SetConfig(path, variant string, stepsPerFrame int) {
+	g.configPath = path
+	g.variant = variant
+	g.stepsPerFrame = stepsPerFrame
+	g.budget = instructionBudget{ips: framesPerSecond * stepsPerFrame}
+}
+
+// SetSpeed sets the number of instructions executed in every second, overriding
+// the instructions per frame set by SetConfig. The speed doesn't need to be a
+// multiple of the frame rate.
+func (g *Game) SetSpeed(ips int) error {
+	b, err := newInstructionBudget(ips)
+	if err != nil {
+		return err
+	}
+	g.budget = b
+	return nil
 }
 
+// SetDebug enters or leaves debug mode. Rewind is only enabled in debug mode,
+// where it can be used to step back, since it slows down every instruction.
 func (g *Game) SetDebug(debug bool) {
 	g.debug = debug
+	g.emulator.SetRewindEnabled(debug)
+	g.lastUpdate = time.Time{}
 	g.adjustWindowSize()
 }
 
 func (g *Game) toggleDebug() {
-	g.debug = !g.debug
-	g.adjustWindowSize()
+	g.SetDebug(!g.debug)
 }
 
-func (g *Game) adjustWindowSize() {
-	if g.debug {
-		ebiten.SetWindowSize(debugWindowWidth, debugWindowHeight)
-	} else {
-		ebiten.SetWindowSize(regularWindowWidth, regularWindowHeight)
+// elapsed returns the time since it was last called, which is one frame the
+// first time, and after entering or leaving debug mode. The time is capped to
+// maxUpdateFrames frames, so that the emulator doesn't race to catch up after
+// a long pause.
+func (g *Game) elapsed() time.Duration {
+	now := time.Now()
+
+	d := emulator.FramePeriod
+
+	if !g.lastUpdate.IsZero() {
+		d = min(now.Sub(g.lastUpdate), maxUpdateFrames*emulator.FramePeriod)
 	}
+
+	g.lastUpdate = now
+
+	return d
+}
+
+func (g *Game) adjustWindowSize() {
+	g.width, g.height = g.state.Width, g.state.Height
+	ebiten.SetWindowSize(g.Layout(0, 0))
+}
+
+// displaySize returns the size of the display area of the window, which
+// depends on the resolution of the emulator.
+func (g *Game) displaySize() (int, int) {
+	return displayArea(g.scale, g.state.Width, g.state.Height, g.aspect)
 }
 
 func (g *Game) Update() error {
@@ -107,13 +266,13 @@ func (g *Game) Update() error {
 
 	for _, key := range inpututil.AppendJustPressedKeys(keys[:0]) {
 		if value, ok := mappings[key]; ok {
-			g.emulator.KeyDown(value)
+			g.emulator.QueueKey(true, value)
 		}
 	}
 
 	for _, key := range inpututil.AppendJustReleasedKeys(keys[:0]) {
 		if value, ok := mappings[key]; ok {
-			g.emulator.KeyUp(value)
+			g.emulator.QueueKey(false, value)
 		}
 	}
 
@@ -121,71 +280,381 @@ func (g *Game) Update() error {
 		g.toggleDebug()
 	}
 
+	if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
+		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+	}
+
 	if g.debug {
 		if inpututil.IsKeyJustPressed(ebiten.KeyI) {
 			g.emulator.Clock()
+			g.dumpState()
 		}
 
 		if inpututil.IsKeyJustPressed(ebiten.KeyO) {
-			if err := g.step(); err != nil {
+			if ebiten.IsKeyPressed(ebiten.KeyShift) {
+				if err := g.stepBatch(batchSteps); err != nil {
+					return fmt.Errorf("step: %v", err)
+				}
+			} else if err := g.step(); err != nil {
 				return fmt.Errorf("step: %v", err)
 			}
+			g.dumpState()
+		}
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+			g.memory.visible = !g.memory.visible
+		}
+
+		if g.memory.visible {
+			g.updateMemoryView()
+		}
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyU) {
+			g.stepBack()
+			g.dumpState()
+		}
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+			g.noTimers = !g.noTimers
+			g.emulator.SetTimersEnabled(!g.noTimers)
+		}
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyY) {
+			g.noCPU = !g.noCPU
+			g.emulator.SetCPUEnabled(!g.noCPU)
+		}
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+			g.saveConfig()
+		}
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyH) {
+			g.saveScreenshot()
+		}
+
+		if inpututil.IsKeyJustPressed(ebiten.KeyN) && len(g.watches) > 0 {
+			g.watchVisible = !g.watchVisible
+		}
+
+		for i, toggle := range quirkToggles {
+			if inpututil.IsKeyJustPressed(quirkToggleKeys[i]) {
+				restart := !ebiten.IsKeyPressed(ebiten.KeyShift)
+				g.toggleQuirk(toggle, restart)
+			}
 		}
 	} else {
 
 		// Ebitengine calls this function (by default) every 1/60 seconds. This
 		// frequency is determined by the Ticks Per Second (TPS) configuration
-		// option. Ebitengine will adjust the time between calls to Update() so that
-		// the code can assume a constant TPS and doesn't have to track the time
-		// internally.
+		// option. Calls can still be late or skipped, for example when the window
+		// is in the background, so the emulator is advanced by the time that has
+		// actually elapsed. This keeps the timers at 60 Hz on average.
 
-		g.emulator.Clock()
+		frames := g.emulator.Tick(g.elapsed())
 
 		// Experimentally, 530 Instructions Per Second (IPS) seems to be a good
-		// speed to emulate CHIP-8 at. The number of instructions to run in a single
-		// call to Update() has been determined by dividing the IPS by the TPS, and
-		// truncating the result.
+		// speed to emulate CHIP-8 at. The default number of instructions to run in
+		// every frame has been determined by dividing the IPS by the frame rate,
+		// and truncating the result. A speed set with -ips is spread over the
+		// frames exactly, carrying the fractional instructions over.
 
-		for range 8 {
+		for range g.budget.steps(frames) {
 			if err := g.step(); err != nil {
 				return fmt.Errorf("step: %v", err)
 			}
+			if b := g.breakpoint(); b != nil {
+				g.log.infof("breakpoint: %v", b)
+				g.SetDebug(true)
+				break
+			}
+		}
+
+		if g.reference != nil {
+			g.checkReference()
 		}
 	}
 
 	g.emulator.State(&g.state)
 
+	if g.state.Width != g.width || g.state.Height != g.height {
+		g.adjustWindowSize()
+	}
+
+	g.updateTitle()
+
 	return nil
 }
 
+// updateTitle sets the title of the window to describe the state of the
+// emulator. The title is only set when it changes, so that it doesn't flicker.
+func (g *Game) updateTitle() {
+	s := titleStatus{
+		rom:     g.rom,
+		variant: g.variant,
+		ips:     g.budget.ips,
+		debug:   g.debug,
+		halted:  g.halted,
+	}
+
+	if title := s.title(); title != g.title {
+		ebiten.SetWindowTitle(title)
+		g.title = title
+	}
+}
+
+// toggleQuirk flips the behavior of toggle. If restart is true, the program is
+// restarted, so that its behavior can be observed from the beginning.
+// Otherwise, the quirk applies from the next instruction.
+func (g *Game) toggleQuirk(toggle quirkToggle, restart bool) {
+	quirks, name := toggle.toggle(g.emulator.Quirks())
+
+	g.log.infof("quirk: %s", name)
+
+	g.emulator.SetQuirks(quirks)
+
+	if restart {
+		g.emulator.Reset()
+		g.halted = false
+	}
+}
+
+// saveScreenshot writes the display to a PNG image in the current directory,
+// named after the current frame.
+func (g *Game) saveScreenshot() {
+	g.emulator.State(&g.state)
+
+	path := fmt.Sprintf("screenshot-%d.png", g.state.FrameCount)
+
+	if err := writeScreenshot(path, &g.state); err != nil {
+		g.log.infof("screenshot: %v", err)
+		return
+	}
+
+	g.log.infof("screenshot: %s", path)
+}
+
+// saveConfig writes the variant, the quirks, and the speed of the emulator to
+// the config file, so that they are used the next time the rom is run.
+func (g *Game) saveConfig() {
+	if g.configPath == "" {
+		g.log.infof("save config: no config file")
+		return
+	}
+
+	c := newConfig(g.variant, g.emulator.Quirks(), g.stepsPerFrame)
+
+	if err := writeConfig(g.configPath, c); err != nil {
+		g.log.infof("save config: %v", err)
+		return
+	}
+
+	g.log.infof("save config: %s", g.configPath)
+}
+
 func (g *Game) step() error {
 	if g.halted {
 		return nil
 	}
+	if g.selfMod != nil {
+		g.emulator.CPUState(&g.state)
+		if m, ok := g.selfMod.Check(g.state.PC); ok {
+			g.log.infof("self-modified: %v", m)
+		}
+	}
 	ok, err := g.emulator.Step()
 	if err != nil {
+		g.dumpTrace()
 		return err
 	}
 	if !ok {
 		g.halted = true
+		g.emulator.State(&g.state)
+		if pc, ranOffEnd := g.emulator.HaltReason(); g.emulator.Exited() {
+			g.log.infof("exited at pc=%04x", pc)
+		} else if ranOffEnd {
+			g.log.infof("halted at pc=%04x, past the end of the rom", pc)
+		} else {
+			g.log.infof("halted at pc=%04x", pc)
+		}
+	}
+	return nil
+}
+
+// stepBatch executes up to n instructions, stopping early if the program halts,
+// and logs the addresses of the first and the last of them.
+func (g *Game) stepBatch(n int) error {
+	var (
+		first, last uint16
+		steps       int
+	)
+
+	for range n {
+		if g.halted {
+			break
+		}
+
+		// Only the program counter is needed before every instruction, so the
+		// whole state is copied once, after the batch.
+
+		g.emulator.CPUState(&g.state)
+
+		if steps == 0 {
+			first = g.state.PC
+		}
+
+		last = g.state.PC
+
+		if err := g.step(); err != nil {
+			return err
+		}
+
+		steps++
+	}
+
+	g.emulator.State(&g.state)
+
+	if steps > 0 {
+		g.log.infof("step: %d instructions, pc=%04x..%04x", steps, first, last)
+	}
+
+	return nil
+}
+
+// checkReference compares the display with the reference, and stops comparing
+// after the first difference. Like in lockstep mode, the frame that has just
+// run is numbered one less than the frame count, since Clock has been called at
+// its start.
+func (g *Game) checkReference() {
+	g.emulator.State(&g.state)
+
+	if _, err := g.emulator.DisplayInto(g.pixels); err != nil {
+		g.log.infof("reference: %v", err)
+		g.reference = nil
+		return
+	}
+
+	if err := g.reference.Check(g.state.FrameCount-1, g.state.Width, g.state.Height, g.pixels); err != nil {
+		g.log.infof("reference: %v", err)
+		g.reference = nil
+	}
+}
+
+// updateMemoryView scrolls the memory view in response to the keys.
+func (g *Game) updateMemoryView() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyPageUp) {
+		g.memory.scroll(-memoryRows)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyPageDown) {
+		g.memory.scroll(memoryRows)
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyJ) {
+		g.memory.jump(g.state.I)
 	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyK) {
+		g.memory.jump(g.state.PC)
+	}
+}
+
+// stepBack restores the state before the most recently executed instruction.
+func (g *Game) stepBack() {
+	n := len(g.emulator.RecentInstructions())
+
+	if n == 0 {
+		g.log.infof("step back: no instruction to revert")
+		return
+	}
+
+	if err := g.emulator.Rewind(n - 1); err != nil {
+		g.log.infof("step back: %v", err)
+		return
+	}
+
+	g.halted = false
+}
+
+// breakpoint returns the first breakpoint hit by the current state, or nil. It
+// is called after every instruction, so it only copies the registers out of the
+// emulator.
+func (g *Game) breakpoint() *debug.Breakpoint {
+	if len(g.breaks) == 0 {
+		return nil
+	}
+
+	g.emulator.CPUState(&g.state)
+
+	for _, b := range g.breaks {
+		if b.Hit(&g.state) {
+			return b
+		}
+	}
+
 	return nil
 }
 
+// dumpTrace logs the instructions leading to the current one, to help
+// understanding how the program got there.
+func (g *Game) dumpTrace() {
+	for _, p := range g.emulator.RecentInstructions() {
+		g.log.infof("trace: %04x %v", p.PC, debug.Instruction(p.Op))
+	}
+}
+
+func (g *Game) dumpState() {
+	g.emulator.State(&g.state)
+	g.log.dumpState(&g.state)
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
 	g.drawDisplay()
 
+	// The display area of the window follows the resolution of the emulator, so
+	// scaling the active area of the display by an integer factor fills it
+	// horizontally. The vertical factor depends on the aspect.
+
+	width, height := g.displaySize()
+
 	var screenOptions ebiten.DrawImageOptions
-	screenOptions.GeoM.Scale(displayScale, displayScale)
+	screenOptions.GeoM.Scale(float64(width)/float64(g.state.Width), float64(height)/float64(g.state.Height))
+
+	screen.DrawImage(g.display.SubImage(image.Rect(0, 0, g.state.Width, g.state.Height)).(*ebiten.Image), &screenOptions)
+
+	if g.debug && g.memory.visible {
+		g.drawMemoryPanel()
+
+		// Scale the memory view to cover as much of the display as possible, since
+		// its size depends on the scale and the resolution.
 
-	screen.DrawImage(g.display, &screenOptions)
+		scale := min(float64(width)/float64(memoryPanelWidth), float64(height)/float64(memoryPanelHeight))
+
+		var memoryPanelOptions ebiten.DrawImageOptions
+		memoryPanelOptions.GeoM.Scale(scale, scale)
+
+		screen.DrawImage(g.memoryPanel, &memoryPanelOptions)
+	}
+
+	if g.debug && g.watchVisible {
+		g.watchPanel.Fill(color.RGBA{R: 0x10, G: 0x10, B: 0x10, A: 0xff})
+
+		ebitenutil.DebugPrint(g.watchPanel, watchText(g.watches, &g.state))
+
+		// Pin the watch panel to the top right corner of the display.
+
+		var watchPanelOptions ebiten.DrawImageOptions
+		watchPanelOptions.GeoM.Scale(debugPanelScale, debugPanelScale)
+		watchPanelOptions.GeoM.Translate(float64(width-debugPanelScale*g.watchPanel.Bounds().Dx()), 0)
+
+		screen.DrawImage(g.watchPanel, &watchPanelOptions)
+	}
 
 	if g.debug {
 		g.drawDebugPanel()
 
 		var debugPanelOptions ebiten.DrawImageOptions
 		debugPanelOptions.GeoM.Scale(debugPanelScale, debugPanelScale)
-		debugPanelOptions.GeoM.Translate(0, displayHeight)
+		debugPanelOptions.GeoM.Translate(0, float64(height))
 
 		screen.DrawImage(g.debugPanel, &debugPanelOptions)
 	}
@@ -193,20 +662,47 @@ func (g *Game) Draw(screen *ebiten.Image) {
 
 func (g *Game) drawDisplay() {
 
-	// This uses the same color palette of the original Game Boy, as documented by
-	// https://en.wikipedia.org/wiki/List_of_video_game_console_palettes.
+	drawPixels(g.display, &g.state.Display, g.state.Width, g.state.Height)
+}
 
-	for y := range g.state.Display {
-		for x := range g.state.Display[y] {
-			if g.state.Display[y][x] != 0 {
-				g.display.Set(x, y, color.RGBA{R: 0x29, G: 0x41, B: 0x39, A: 0xff})
-			} else {
-				g.display.Set(x, y, color.RGBA{R: 0x7b, G: 0x82, B: 0x10, A: 0xff})
-			}
+// drawPixels draws the top left width×height pixels of display to the top left
+// corner of dst.
+func drawPixels(dst *ebiten.Image, display *emulator.Display, width, height int) {
+	for y := range height {
+		for x := range width {
+			dst.Set(x, y, palette[display[y][x]&emulator.AllPlanes])
 		}
 	}
 }
 
+// drawMemoryPanel draws the memory view, highlighting the bytes executed so far,
+// the byte at I, and the instruction at PC.
+func (g *Game) drawMemoryPanel() {
+	g.memoryPanel.Fill(color.RGBA{R: 0x10, G: 0x10, B: 0x10, A: 0xff})
+
+	highlight := func(addr uint16, c color.Color) {
+		if row, column, ok := g.memory.cell(addr); ok {
+			x := float32(column * debugCharacterWidth)
+			y := float32(row * debugCharacterHeight)
+			vector.DrawFilledRect(g.memoryPanel, x, y, 2*debugCharacterWidth, debugCharacterHeight, c, false)
+		}
+	}
+
+	executed := g.emulator.ExecutedMap()
+
+	for addr := g.memory.start; addr < g.memory.start+memoryRows*memoryColumns; addr++ {
+		if executed[addr/8]&(1<<(addr%8)) != 0 {
+			highlight(uint16(addr), color.RGBA{R: 0x20, G: 0x50, B: 0x20, A: 0xff})
+		}
+	}
+
+	highlight(g.state.I, color.RGBA{R: 0x20, G: 0x40, B: 0xa0, A: 0xff})
+	highlight(g.state.PC, color.RGBA{R: 0xa0, G: 0x20, B: 0x20, A: 0xff})
+	highlight(g.state.PC+1, color.RGBA{R: 0xa0, G: 0x20, B: 0x20, A: 0xff})
+
+	ebitenutil.DebugPrint(g.memoryPanel, g.memory.text(&g.state.Memory))
+}
+
 func (g *Game) drawDebugPanel() {
 	var w strings.Builder
 
@@ -233,65 +729,539 @@ func (g *Game) drawDebugPanel() {
 	out("i=%04x ", g.state.I)
 	out("sp=%02x ", g.state.SP)
 	out("dt=%02x ", g.state.DT)
-	out("st=%02x\n\n", g.state.ST)
+	out("st=%02x\n", g.state.ST)
+	out("timers=%s cpu=%s\n\n", onOff(!g.noTimers), onOff(!g.noCPU))
+	out("History:\n")
+
+	history := g.emulator.RecentInstructions()
+
+	for _, p := range history[max(0, len(history)-historyRows):] {
+		out("%04x %v\n", p.PC, debug.Instruction(p.Op))
+	}
+
+	for range historyRows - min(len(history), historyRows) {
+		out("\n")
+	}
+
+	out("\n")
+	out("Quirks:\n")
+
+	quirks := g.emulator.Quirks()
+
+	for i, toggle := range quirkToggles {
+		if i > 0 {
+			out(" ")
+		}
+		out("%s", toggle.current(quirks))
+	}
+
+	out("\n\n")
 	out("[I] Advance time\n")
-	out("[O] Step instruction\n")
-	out("[P] Toggle debug mode\n")
+	out("[O] Step instruction, [Shift+O] Step %d, [U] Step back\n", batchSteps)
+	out("[P] Toggle debug mode, [G] Save config, [H] Screenshot\n")
+	out("[T] Toggle timers, [Y] Toggle CPU, [N] Toggle watch\n")
+	out("[M] Toggle memory, [PgUp/PgDn] Scroll, [J/K] Go to I/PC\n")
+	keys := fmt.Sprintf("%v-%v", quirkToggleKeys[0], quirkToggleKeys[len(quirkToggles)-1])
+	out("[%s] Toggle quirk and restart, [Shift+%s] Toggle quirk\n", keys, keys)
+	out("[F11] Toggle fullscreen\n")
 
 	g.debugPanel.Clear()
 
 	ebitenutil.DebugPrint(g.debugPanel, w.String())
 }
 
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// Layout returns a fixed size, independent of the size of the window. In
+// fullscreen mode, or when the window is resized, Ebitengine scales the screen
+// to fit the window, preserving the aspect ratio and filling the rest of the
+// window with black bars. Scaling by a fractional factor uses a pixelated
+// filter, so the pixels of the display stay sharp.
 func (g *Game) Layout(_, _ int) (int, int) {
+	width, height := g.displaySize()
+
 	if g.debug {
-		return displayWidth, displayHeight + debugPanelHeight
+		return max(width, debugPanelWidth), height + debugPanelHeight
+	}
+
+	return width, height
+}
+
+// compareGap is the width, in pixels of the window, of the gap between the
+// displays in compare mode.
+const compareGap = 8
+
+// CompareGame runs a comparison in a window, showing the displays of the two
+// emulators side by side, labeled with their quirks. Debug mode is not
+// available in compare mode.
+type CompareGame struct {
+	comparison *comparison
+	log        *logger
+	scale      int
+	aspect     aspect
+	diverged   bool          // The emulators have diverged, and it was logged
+	backlog    time.Duration // Time elapsed and not yet emulated
+	lastUpdate time.Time
+	width      int // Width of the window the layout was computed for
+	height     int // Height of the window the layout was computed for
+	sides      [2]compareView
+}
+
+// compareView holds what is needed to draw one side of a comparison.
+type compareView struct {
+	state   emulator.State
+	display *ebiten.Image
+}
+
+func NewCompareGame(c *comparison, l *logger, scale int, a aspect) (*CompareGame, error) {
+	w, _, err := windowSize(scale, emulator.MaxDisplayWidth, emulator.MaxDisplayHeight, a)
+	if err != nil {
+		return nil, err
+	}
+
+	if 2*w+compareGap > maxWindowSize {
+		return nil, fmt.Errorf("window too large for scale %d", scale)
+	}
+
+	g := CompareGame{
+		comparison: c,
+		log:        l,
+		scale:      scale,
+		aspect:     a,
+	}
+
+	for i := range g.sides {
+		g.sides[i].display = ebiten.NewImage(emulator.MaxDisplayWidth, emulator.MaxDisplayHeight)
+		c.sides[i].emulator.State(&g.sides[i].state)
+	}
+
+	g.adjustWindowSize()
+
+	return &g, nil
+}
+
+func (g *CompareGame) adjustWindowSize() {
+	g.width, g.height = g.Layout(0, 0)
+	ebiten.SetWindowSize(g.width, g.height)
+}
+
+func (g *CompareGame) Update() error {
+	var keys [16]ebiten.Key
+
+	for _, key := range inpututil.AppendJustPressedKeys(keys[:0]) {
+		if value, ok := mappings[key]; ok {
+			g.comparison.queueKey(true, value)
+		}
+	}
+
+	for _, key := range inpututil.AppendJustReleasedKeys(keys[:0]) {
+		if value, ok := mappings[key]; ok {
+			g.comparison.queueKey(false, value)
+		}
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyF11) {
+		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+	}
+
+	// Like in the normal mode, the emulators are advanced by the time that has
+	// actually elapsed, capped to a few frames.
+
+	now := time.Now()
+
+	if g.lastUpdate.IsZero() {
+		g.backlog += emulator.FramePeriod
+	} else {
+		g.backlog += min(now.Sub(g.lastUpdate), maxUpdateFrames*emulator.FramePeriod)
+	}
+
+	g.lastUpdate = now
+
+	for ; g.backlog >= emulator.FramePeriod; g.backlog -= emulator.FramePeriod {
+		if err := g.comparison.runFrame(); err != nil {
+			return fmt.Errorf("run frame: %v", err)
+		}
+	}
+
+	for i := range g.sides {
+		g.comparison.sides[i].emulator.State(&g.sides[i].state)
+	}
+
+	if !g.diverged && g.comparison.diverged() {
+		g.diverged = true
+		g.log.infof("compare: diverged at frame %d", g.sides[0].state.FrameCount)
+	}
+
+	if width, height := g.Layout(0, 0); width != g.width || height != g.height {
+		g.adjustWindowSize()
+	}
+
+	return nil
+}
+
+func (g *CompareGame) Draw(screen *ebiten.Image) {
+	left := 0
+
+	for i := range g.sides {
+		v := &g.sides[i]
+
+		drawPixels(v.display, &v.state.Display, v.state.Width, v.state.Height)
+
+		width, height := displayArea(g.scale, v.state.Width, v.state.Height, g.aspect)
+
+		var options ebiten.DrawImageOptions
+		options.GeoM.Scale(float64(width)/float64(v.state.Width), float64(height)/float64(v.state.Height))
+		options.GeoM.Translate(float64(left), 0)
+
+		screen.DrawImage(v.display.SubImage(image.Rect(0, 0, v.state.Width, v.state.Height)).(*ebiten.Image), &options)
+
+		label := g.comparison.sides[i].label
+
+		if g.comparison.sides[i].halted {
+			label += " (halted)"
+		}
+
+		ebitenutil.DebugPrintAt(screen, label, left, 0)
+
+		left += width + compareGap
 	}
+}
+
+// Layout returns the size of the two displays side by side, separated by a gap.
+func (g *CompareGame) Layout(_, _ int) (int, int) {
+	var width, height int
 
-	return displayWidth, displayHeight
+	for i := range g.sides {
+		w, h := displayArea(g.scale, g.sides[i].state.Width, g.sides[i].state.Height, g.aspect)
+		width += w
+		height = max(height, h)
+	}
+
+	return width + compareGap, height
 }
 
 func main() {
-	if err := run(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
 		log.Fatalf("error: %v", err)
 	}
 }
 
-func run() error {
-	var debug bool
+// commands lists the commands supported by the program, for the usage message.
+var commands = []struct {
+	name        string
+	description string
+}{
+	{"run", "Run a rom"},
+	{"disasm", "Print the instructions of a rom"},
+	{"info", "Describe the size, the hash, and the instructions of a rom"},
+	{"calls", "Print the subroutines of a rom and the subroutines they call"},
+}
+
+// run executes the command named by the first argument. For compatibility, a
+// rom passed without a command is run as if by the run command.
+func run(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "run":
+			return runGame(args[1:])
+		case "disasm":
+			return disasm(args[1:], os.Stdout)
+		case "info":
+			return info(args[1:], os.Stdout)
+		case "calls":
+			return calls(args[1:], os.Stdout)
+		}
+	}
+
+	return runGame(args)
+}
+
+// usage returns the usage function of the run command, which also lists the
+// other commands, because it is the one shown when no command is given.
+func usage(fs *flag.FlagSet) func() {
+	return func() {
+		out := fs.Output()
+
+		fmt.Fprintf(out, "Usage: chip8 [run] [flags] ROM\n")
+		fmt.Fprintf(out, "       chip8 COMMAND ROM\n\n")
+		fmt.Fprintf(out, "Commands:\n")
+
+		for _, c := range commands {
+			fmt.Fprintf(out, "  %-8s %s\n", c.name, c.description)
+		}
+
+		fmt.Fprintf(out, "\nFlags of the run command:\n")
 
-	flag.BoolVar(&debug, "debug", false, "Start the emulator in debug mode")
-	flag.Parse()
+		fs.PrintDefaults()
 
-	if flag.NArg() != 1 {
+		fmt.Fprintf(out, "\nQuirks accepted by -quirks:\n")
+
+		for _, q := range emulator.QuirkInfo() {
+			if q.Default {
+				fmt.Fprintf(out, "  %-16s %s (default)\n", q.Name, q.Description)
+			} else {
+				fmt.Fprintf(out, "  %-16s %s\n", q.Name, q.Description)
+			}
+		}
+	}
+}
+
+// runGame implements the run command, which runs a rom in a window.
+func runGame(args []string) error {
+	var (
+		debugMode     bool
+		fullscreen    bool
+		scale         int
+		aspectName    string
+		breaks        []*debug.Breakpoint
+		variant       string
+		quirks        string
+		logLevel      string
+		selfMod       bool
+		beepHit       bool
+		reference     string
+		watchList     string
+		benchmarkMode bool
+		compare       string
+		maxDraws      int
+		ips           int
+	)
+
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.BoolVar(&debugMode, "debug", false, "Start the emulator in debug mode")
+	fs.BoolVar(&benchmarkMode, "benchmark", false, fmt.Sprintf("Run the rom as fast as possible for %v without a window, and print the speed of the emulator", benchmarkDuration))
+	fs.BoolVar(&fullscreen, "fullscreen", false, "Start the emulator in fullscreen mode")
+	fs.IntVar(&scale, "scale", defaultScale, "Width in pixels of a pixel of the display")
+	fs.StringVar(&aspectName, "aspect", aspectNames[aspectNative], fmt.Sprintf("Aspect ratio of the display (%s)", strings.Join(aspectNames, ", ")))
+	fs.Func("break", "Enter debug mode at an address, or when a condition holds (can be repeated)", func(s string) error {
+		b, err := debug.ParseBreakpoint(s)
+		if err != nil {
+			return err
+		}
+		breaks = append(breaks, b)
+		return nil
+	})
+	fs.BoolVar(&beepHit, "beep-on-collision", false, "Beep every time a sprite collides with the display, to debug collisions")
+	fs.IntVar(&ips, "ips", 0, "Instructions executed in every second, or 0 to use the instructions per frame of the config file")
+	fs.IntVar(&maxDraws, "max-draws", 0, "Maximum number of sprites drawn in every frame, or 0 for no limit")
+	fs.StringVar(&reference, "reference", "", "Compare the display with the frames of a reference interpreter stored in this file")
+	fs.BoolVar(&selfMod, "log-selfmod", false, "Log the instructions executed after the rom overwrote them")
+	fs.StringVar(&watchList, "watch", "", "Comma-separated list of registers shown over the display in debug mode (v0-vf, i, sp, pc, dt, st)")
+	fs.StringVar(&logLevel, "log-level", "info", fmt.Sprintf("Verbosity of the log (%s)", strings.Join(logLevels, ", ")))
+	fs.StringVar(&variant, "variant", "", fmt.Sprintf("Interpreter whose quirks are emulated (%s)", strings.Join(emulator.VariantNames(), ", ")))
+	fs.StringVar(&quirks, "quirks", "", "Comma-separated list of quirks to apply on top of the variant (see below)")
+	fs.StringVar(&compare, "compare", "", "Comma-separated list of quirks to apply on top of the variant in a second emulator, shown side by side with the first")
+	fs.Usage = usage(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
 		return fmt.Errorf("invalid number of arguments")
 	}
 
-	rom, err := os.ReadFile(flag.Arg(0))
+	level, err := parseLogLevel(logLevel)
 	if err != nil {
-		return fmt.Errorf("read file: %v", err)
+		return fmt.Errorf("parse log level: %v", err)
 	}
 
-	context := audio.NewContext(44100)
+	rom, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("read file: %v", err)
+	}
 
 	e := emulator.New()
 
+	// The config file saved in debug mode provides the defaults, which are
+	// overridden by the flags.
+
+	configPath := sidecarPath(fs.Arg(0))
+
+	cfg, err := readConfig(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		cfg = newConfig("", emulator.DefaultQuirks(), emulator.DefaultStepsPerFrame)
+	} else if err != nil {
+		return fmt.Errorf("read config: %v", err)
+	}
+
+	base, err := cfg.quirks()
+	if err != nil {
+		return fmt.Errorf("read config: %v", err)
+	}
+
+	if variant != "" {
+		v, err := emulator.ParseVariant(variant)
+		if err != nil {
+			return fmt.Errorf("parse variant: %v", err)
+		}
+		base = v.Quirks()
+	} else {
+		variant = cfg.Variant
+	}
+
+	q, err := emulator.ParseQuirks(quirks, base)
+	if err != nil {
+		return fmt.Errorf("parse quirks: %v", err)
+	}
+
+	e.SetQuirks(q)
+	e.SetMaxDrawsPerFrame(maxDraws)
+
 	if err := e.Load(rom); err != nil {
 		return fmt.Errorf("load: %w", err)
 	}
 
-	e.SetSound(func() {
+	if benchmarkMode {
+		// The benchmark runs at the speed the rom would run at in a window, so
+		// that the timers are advanced at the same rate.
+
+		budget := instructionBudget{ips: framesPerSecond * cfg.StepsPerFrame}
+
+		if ips != 0 {
+			if budget, err = newInstructionBudget(ips); err != nil {
+				return fmt.Errorf("set speed: %v", err)
+			}
+		}
+
+		r, err := benchmark(e, budget, benchmarkDuration)
+		if err != nil {
+			return fmt.Errorf("benchmark: %v", err)
+		}
+		printBenchmark(os.Stdout, r)
+		return nil
+	}
+
+	context := audio.NewContext(sampleRate)
+
+	play := func() {
 		context.NewPlayerFromBytes(beep).Play()
-	})
+	}
+
+	// Programs that load an XO-CHIP audio pattern play it instead of the beep.
+
+	sound := func(e *emulator.Emulator) func() {
+		return func() {
+			if pattern, rate, ok := e.AudioPattern(); ok {
+				context.NewPlayerFromBytes(renderPattern(pattern, rate, patternFrames)).Play()
+				return
+			}
+			play()
+		}
+	}
+
+	if compare != "" {
+		ebiten.SetFullscreen(fullscreen)
+		return runCompare(rom, cfg.StepsPerFrame, q, base, compare, sound, scale, aspectName, newLogger(os.Stderr, level))
+	}
+
+	e.SetSound(sound(e))
+
+	// The collision beep is a debugging aid, independent of the sound timer.
+
+	if beepHit {
+		e.SetFlagTracer(func(_ uint16, value uint8, cause emulator.FlagCause) {
+			if cause == emulator.FlagCollision && value == 1 {
+				play()
+			}
+		})
+	}
 
 	g, err := NewGame(e)
 	if err != nil {
 		return fmt.Errorf("create game: %v", err)
 	}
 
-	g.SetDebug(debug)
+	a, err := parseAspect(aspectName)
+	if err != nil {
+		return fmt.Errorf("parse aspect: %v", err)
+	}
+
+	if err := g.SetScale(scale, a); err != nil {
+		return fmt.Errorf("set scale: %v", err)
+	}
+
+	g.SetLogger(newLogger(os.Stderr, level))
+	g.SetBreakpoints(breaks)
+	g.SetConfig(configPath, variant, cfg.StepsPerFrame)
+
+	if ips != 0 {
+		if err := g.SetSpeed(ips); err != nil {
+			return fmt.Errorf("set speed: %v", err)
+		}
+	}
+
+	e.SetDrawLimitWarning(func(frame uint64) {
+		g.log.infof("draws: more than %d sprites in frame %d, skipping the others", maxDraws, frame)
+	})
+
+	if watchList != "" {
+		watches, err := parseWatches(watchList)
+		if err != nil {
+			return fmt.Errorf("parse watches: %v", err)
+		}
+		g.SetWatches(watches)
+	}
+
+	if reference != "" {
+		data, err := os.ReadFile(reference)
+		if err != nil {
+			return fmt.Errorf("read reference: %v", err)
+		}
+		r, err := trace.ParseReference(data)
+		if err != nil {
+			return fmt.Errorf("parse reference: %v", err)
+		}
+		g.SetReference(r)
+	}
+
+	if selfMod {
+		g.SetSelfModTracer(debug.NewSelfModTracer(e, emulator.ProgramStart, emulator.ProgramStart+uint16(len(rom))))
+	}
+	g.SetDebug(debugMode)
+	g.SetROMName(filepath.Base(fs.Arg(0)))
+	g.updateTitle()
+
+	ebiten.SetFullscreen(fullscreen)
+
+	if err := ebiten.RunGame(g); err != nil {
+		return fmt.Errorf("run game: %v", err)
+	}
+
+	return nil
+}
+
+// runCompare runs the rom in compare mode. The left emulator uses the quirks
+// from the command line, while the right one applies the quirks listed in
+// compare on top of the base quirks. Only the left emulator plays sound.
+func runCompare(rom []byte, stepsPerFrame int, left, base emulator.Quirks, compare string, sound func(*emulator.Emulator) func(), scale int, aspectName string, l *logger) error {
+	right, err := emulator.ParseQuirks(compare, base)
+	if err != nil {
+		return fmt.Errorf("parse compare: %v", err)
+	}
+
+	c, err := newComparison(rom, stepsPerFrame, left, right)
+	if err != nil {
+		return err
+	}
+
+	c.sides[0].emulator.SetSound(sound(c.sides[0].emulator))
+
+	a, err := parseAspect(aspectName)
+	if err != nil {
+		return fmt.Errorf("parse aspect: %v", err)
+	}
+
+	g, err := NewCompareGame(c, l, scale, a)
+	if err != nil {
+		return fmt.Errorf("create game: %v", err)
+	}
 
-	ebiten.SetWindowTitle("CHIP-8 Emulator")
+	ebiten.SetWindowTitle(windowTitle)
 
 	if err := ebiten.RunGame(g); err != nil {
 		return fmt.Errorf("run game: %v", err)
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+const (
+	memoryRows    = 20 // Rows of the memory view
+	memoryColumns = 16 // Bytes in every row of the memory view
+)
+
+// memoryPrefix is the width, in characters, of the address at the beginning of
+// every row of the memory view.
+const memoryPrefix = len("0000: ")
+
+// memoryView is a window over the memory, shown as a hexadecimal dump.
+type memoryView struct {
+	visible bool
+	start   int // Address of the first byte shown, a multiple of memoryColumns
+}
+
+// scroll moves the view by the given number of rows, towards higher addresses
+// if rows is positive.
+func (v *memoryView) scroll(rows int) {
+	v.setStart(v.start + rows*memoryColumns)
+}
+
+// jump moves the view so that the row containing addr is in the middle.
+func (v *memoryView) jump(addr uint16) {
+	v.setStart(int(addr) - int(addr)%memoryColumns - memoryRows/2*memoryColumns)
+}
+
+func (v *memoryView) setStart(start int) {
+	var memory emulator.Memory
+	v.start = max(0, min(start, len(memory)-memoryRows*memoryColumns))
+}
+
+// text returns the dump of the visible memory, one row per line.
+func (v *memoryView) text(memory *emulator.Memory) string {
+	var b strings.Builder
+
+	for row := range memoryRows {
+		addr := v.start + row*memoryColumns
+
+		fmt.Fprintf(&b, "%04x:", addr)
+
+		for _, value := range memory[addr : addr+memoryColumns] {
+			fmt.Fprintf(&b, " %02x", value)
+		}
+
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// cell returns the position, in characters, of the byte at addr in the text
+// returned by text. It returns false if the byte is not visible.
+func (v *memoryView) cell(addr uint16) (row, column int, ok bool) {
+	offset := int(addr) - v.start
+
+	if offset < 0 || offset >= memoryRows*memoryColumns {
+		return 0, 0, false
+	}
+
+	return offset / memoryColumns, memoryPrefix + 3*(offset%memoryColumns), true
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestMemoryViewText(t *testing.T) {
+	var memory emulator.Memory
+
+	memory[0x210] = 0xab
+	memory[0x21f] = 0xcd
+
+	v := memoryView{start: 0x200}
+
+	lines := strings.Split(v.text(&memory), "\n")
+
+	if want := "0210: ab 00 00 00 00 00 00 00 00 00 00 00 00 00 00 cd"; lines[1] != want {
+		t.Fatalf("got %q, want %q", lines[1], want)
+	}
+
+	row, column, ok := v.cell(0x21f)
+	if !ok {
+		t.Fatal("cell should be visible")
+	}
+	if got := lines[row][column : column+2]; got != "cd" {
+		t.Fatalf("cell: got %q, want %q", got, "cd")
+	}
+
+	if _, _, ok := v.cell(0x1ff); ok {
+		t.Fatal("cell before the view should not be visible")
+	}
+}
+
+func TestMemoryViewScroll(t *testing.T) {
+	var v memoryView
+
+	v.scroll(-1)
+
+	if v.start != 0 {
+		t.Fatalf("start: got %04x, want 0000", v.start)
+	}
+
+	v.scroll(len(emulator.Memory{}))
+
+	if want := len(emulator.Memory{}) - memoryRows*memoryColumns; v.start != want {
+		t.Fatalf("start: got %04x, want %04x", v.start, want)
+	}
+
+	v.jump(0x2a5)
+
+	if want := 0x2a0 - memoryRows/2*memoryColumns; v.start != want {
+		t.Fatalf("start: got %04x, want %04x", v.start, want)
+	}
+}
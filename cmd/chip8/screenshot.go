@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// palette maps the bitplanes of a pixel to its color. Pixels that are off and
+// pixels in the first plane use the color palette of the original Game Boy, as
+// documented by https://en.wikipedia.org/wiki/List_of_video_game_console_palettes.
+// Pixels in the second plane, which only XO-CHIP uses, and pixels in both planes
+// use shades in between.
+var palette = [emulator.AllPlanes + 1]color.RGBA{
+	{R: 0x7b, G: 0x82, B: 0x10, A: 0xff},
+	{R: 0x29, G: 0x41, B: 0x39, A: 0xff},
+	{R: 0xa5, G: 0xad, B: 0x42, A: 0xff},
+	{R: 0x52, G: 0x61, B: 0x29, A: 0xff},
+}
+
+// displayImage returns the active area of the display of state, with every
+// pixel colored by the planes it is on, like in the window. If the second plane
+// is not used, the image only has the two colors of the first plane.
+func displayImage(state *emulator.State) *image.Paletted {
+	colors := 2
+
+	for y := range state.Height {
+		for x := range state.Width {
+			if state.Display[y][x]&^1 != 0 {
+				colors = len(palette)
+			}
+		}
+	}
+
+	var p color.Palette
+
+	for _, c := range palette[:colors] {
+		p = append(p, c)
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, state.Width, state.Height), p)
+
+	for y := range state.Height {
+		for x := range state.Width {
+			img.SetColorIndex(x, y, state.Display[y][x]&emulator.AllPlanes)
+		}
+	}
+
+	return img
+}
+
+// writeScreenshot writes the display of state to path, as a PNG or a GIF
+// image, depending on the extension of path.
+func writeScreenshot(path string, state *emulator.State) error {
+	var (
+		b   bytes.Buffer
+		err error
+	)
+
+	switch ext := filepath.Ext(path); ext {
+	case ".png":
+		err = png.Encode(&b, displayImage(state))
+	case ".gif":
+		err = gif.Encode(&b, displayImage(state), nil)
+	default:
+		return fmt.Errorf("unsupported image format %q", ext)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b.Bytes(), 0o644)
+}
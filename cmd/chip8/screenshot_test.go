@@ -0,0 +1,70 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestWriteScreenshot(t *testing.T) {
+	state := emulator.State{Width: emulator.DisplayWidth, Height: emulator.DisplayHeight}
+
+	state.Display[0][1] = 0x1 // First plane only
+	state.Display[0][2] = 0x2 // Second plane only
+	state.Display[0][3] = 0x3 // Both planes
+
+	for _, name := range []string{"screen.png", "screen.gif"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+
+			if err := writeScreenshot(path, &state); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer f.Close()
+
+			img, _, err := image.Decode(f)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			if got, want := img.Bounds(), image.Rect(0, 0, emulator.DisplayWidth, emulator.DisplayHeight); got != want {
+				t.Fatalf("bounds: got %v, want %v", got, want)
+			}
+
+			for x, want := range palette {
+				if got := color.RGBAModel.Convert(img.At(x, 0)); got != want {
+					t.Errorf("pixel %d: got %v, want %v", x, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDisplayImageSinglePlane(t *testing.T) {
+	state := emulator.State{Width: emulator.DisplayWidth, Height: emulator.DisplayHeight}
+
+	state.Display[0][0] = 0x1
+
+	// Without pixels in the second plane, the image only has two colors.
+
+	if got := len(displayImage(&state).Palette); got != 2 {
+		t.Fatalf("colors: got %d, want 2", got)
+	}
+}
+
+func TestWriteScreenshotUnsupported(t *testing.T) {
+	var state emulator.State
+
+	if err := writeScreenshot(filepath.Join(t.TempDir(), "screen.bmp"), &state); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// framesPerSecond is the number of frames emulated in every second.
+const framesPerSecond = int(time.Second / emulator.FramePeriod)
+
+// instructionBudget spreads the instructions executed in every second over the
+// frames. When the speed is not a multiple of the frame rate, the fraction of an
+// instruction left over by a frame is carried over to the next one, so that the
+// average speed over many frames is exact.
+type instructionBudget struct {
+	ips       int // Instructions executed in every second
+	remainder int // Fraction of an instruction carried over, in 1/framesPerSecond
+}
+
+func newInstructionBudget(ips int) (instructionBudget, error) {
+	if ips <= 0 {
+		return instructionBudget{}, fmt.Errorf("invalid speed %d", ips)
+	}
+	return instructionBudget{ips: ips}, nil
+}
+
+// steps returns the number of instructions to execute in the next frames.
+func (b *instructionBudget) steps(frames int) int {
+	total := b.remainder + frames*b.ips
+	b.remainder = total % framesPerSecond
+	return total / framesPerSecond
+}
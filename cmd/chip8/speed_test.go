@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestInstructionBudget(t *testing.T) {
+	b, err := newInstructionBudget(530)
+	if err != nil {
+		t.Fatalf("new budget: %v", err)
+	}
+
+	// 530 instructions per second are 8.83 instructions per frame, so frames
+	// run either 8 or 9 instructions.
+
+	var total int
+
+	for frame := 1; frame <= 1000; frame++ {
+		n := b.steps(1)
+
+		if n != 8 && n != 9 {
+			t.Fatalf("frame %d: got %d instructions", frame, n)
+		}
+
+		total += n
+
+		if want := frame * 530 / 60; total < want-1 || total > want+1 {
+			t.Fatalf("frame %d: got %d instructions in total, want %d", frame, total, want)
+		}
+	}
+
+	// Running several frames at once gives the same result.
+
+	if got := b.steps(60); got != 530 {
+		t.Fatalf("one second: got %d instructions, want 530", got)
+	}
+}
+
+func TestInstructionBudgetInvalid(t *testing.T) {
+	if _, err := newInstructionBudget(0); err == nil {
+		t.Fatalf("no error")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// quirkToggle flips a behavior of the emulator between the two quirks
+// describing its values.
+type quirkToggle struct {
+	names [2]string // Quirks selecting each value of the behavior
+}
+
+// newQuirkToggles returns a toggle for every behavior described by
+// [emulator.QuirkInfo], in the same order, so that quirks added to the emulator
+// can be toggled without changes to the front-end.
+func newQuirkToggles() []quirkToggle {
+	var (
+		toggles []quirkToggle
+		fields  []string
+	)
+
+	for _, q := range emulator.QuirkInfo() {
+		i := slices.Index(fields, q.Field)
+
+		if i < 0 {
+			fields = append(fields, q.Field)
+			toggles = append(toggles, quirkToggle{names: [2]string{q.Name}})
+		} else {
+			toggles[i].names[1] = q.Name
+		}
+	}
+
+	return toggles
+}
+
+// current returns the name of the quirk describing the behavior in q.
+func (t quirkToggle) current(q emulator.Quirks) string {
+	if slices.Contains(q.Names(), t.names[0]) {
+		return t.names[0]
+	}
+	return t.names[1]
+}
+
+// toggle returns q with the behavior flipped, and the name of the quirk that
+// was applied.
+func (t quirkToggle) toggle(q emulator.Quirks) (emulator.Quirks, string) {
+	name := t.names[0]
+
+	if t.current(q) == name {
+		name = t.names[1]
+	}
+
+	// The names come from the emulator, so they are always valid.
+
+	q, _ = emulator.ParseQuirks(name, q)
+
+	return q, name
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestQuirkToggles(t *testing.T) {
+	toggles := newQuirkToggles()
+
+	// Every quirk belongs to exactly one toggle.
+
+	if got, want := 2*len(toggles), len(emulator.QuirkNames()); got != want {
+		t.Fatalf("got %d quirks in toggles, want %d", got, want)
+	}
+
+	base := emulator.DefaultQuirks()
+
+	for _, toggle := range toggles {
+		before := toggle.current(base)
+
+		q, name := toggle.toggle(base)
+
+		if name == before || toggle.current(q) != name {
+			t.Fatalf("%v: toggled from %q to %q, current %q", toggle.names, before, name, toggle.current(q))
+		}
+
+		if q == base {
+			t.Fatalf("%v: quirks unchanged", toggle.names)
+		}
+
+		if q, _ = toggle.toggle(q); q != base {
+			t.Fatalf("%v: toggling twice got %+v, want %+v", toggle.names, q, base)
+		}
+	}
+}
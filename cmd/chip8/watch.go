@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// watchWidth is the width, in characters, of the longest entry of the watch
+// panel, like "pc=0200".
+const watchWidth = len("pc=0000")
+
+// watch is a register, or another part of the machine state, pinned to the
+// watch panel.
+type watch struct {
+	name   string
+	digits int // Hexadecimal digits of the value
+	get    func(*emulator.State) int
+}
+
+// parseWatches parses a comma-separated list of registers (v0 to vf, i, sp, pc,
+// dt, and st), using the same names as the conditions of breakpoints.
+func parseWatches(list string) ([]watch, error) {
+	var watches []watch
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+
+		w, err := parseWatch(name)
+		if err != nil {
+			return nil, err
+		}
+
+		watches = append(watches, w)
+	}
+
+	return watches, nil
+}
+
+func parseWatch(name string) (watch, error) {
+	switch name {
+	case "i":
+		return watch{name, 4, func(s *emulator.State) int { return int(s.I) }}, nil
+	case "sp":
+		return watch{name, 2, func(s *emulator.State) int { return int(s.SP) }}, nil
+	case "pc":
+		return watch{name, 4, func(s *emulator.State) int { return int(s.PC) }}, nil
+	case "dt":
+		return watch{name, 2, func(s *emulator.State) int { return int(s.DT) }}, nil
+	case "st":
+		return watch{name, 2, func(s *emulator.State) int { return int(s.ST) }}, nil
+	}
+
+	if len(name) == 2 && name[0] == 'v' {
+		if r, err := strconv.ParseUint(name[1:], 16, 4); err == nil {
+			return watch{name, 2, func(s *emulator.State) int { return int(s.V[r]) }}, nil
+		}
+	}
+
+	return watch{}, fmt.Errorf("invalid register %q", name)
+}
+
+// watchText returns the watch panel, with one line for every watch.
+func watchText(watches []watch, s *emulator.State) string {
+	var b strings.Builder
+
+	for _, w := range watches {
+		fmt.Fprintf(&b, "%s=%0*x\n", w.name, w.digits, w.get(s))
+	}
+
+	return b.String()
+}
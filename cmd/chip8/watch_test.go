@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestWatchText(t *testing.T) {
+	watches, err := parseWatches("v3, i,pc,dt")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	state := emulator.State{I: 0x300, PC: 0x20a, DT: 0x3c}
+	state.V[3] = 0x05
+
+	if got, want := watchText(watches, &state), "v3=05\ni=0300\npc=020a\ndt=3c\n"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseInvalidWatches(t *testing.T) {
+	for _, list := range []string{"", "vg", "v3,", "x"} {
+		if _, err := parseWatches(list); err == nil {
+			t.Errorf("expected an error for %q", list)
+		}
+	}
+}
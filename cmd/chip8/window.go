@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// windowTitle is the title of the window when no rom is loaded.
+const windowTitle = "CHIP-8 Emulator"
+
+// titleStatus is the state of the emulator shown in the title of the window.
+type titleStatus struct {
+	rom     string // Name of the rom file
+	variant string // Name of the variant, or empty if not set
+	ips     int    // Instructions executed in every second
+	debug   bool
+	halted  bool
+}
+
+// title returns the title of the window, with the name of the rom followed by
+// the state of the emulator.
+func (s titleStatus) title() string {
+	if s.rom == "" {
+		return windowTitle
+	}
+
+	var details []string
+
+	if s.variant != "" {
+		details = append(details, s.variant)
+	}
+
+	details = append(details, fmt.Sprintf("%d IPS", s.ips))
+
+	if s.debug {
+		details = append(details, "debug")
+	}
+
+	if s.halted {
+		details = append(details, "halted")
+	}
+
+	return fmt.Sprintf("%s - %s (%s)", windowTitle, s.rom, strings.Join(details, ", "))
+}
+
+// defaultScale is the size, in pixels of the window, of a pixel of the display.
+const defaultScale = 10
+
+// maxWindowSize is the largest width or height of the display area of the
+// window. It rejects scale factors producing windows that no monitor can show.
+const maxWindowSize = 8192
+
+// aspect is the ratio between the width and the height of the display area of
+// the window.
+type aspect int
+
+const (
+	aspectNative aspect = iota // Square pixels, which make the default display 2:1
+	aspectSquare               // Pixels as tall as needed to make the display square
+)
+
+var aspectNames = []string{
+	aspectNative: "2:1",
+	aspectSquare: "square",
+}
+
+func parseAspect(s string) (aspect, error) {
+	for a, name := range aspectNames {
+		if name == s {
+			return aspect(a), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown aspect %q (valid aspects: %s)", s, strings.Join(aspectNames, ", "))
+}
+
+// displayArea returns the size of the display area of the window for a display
+// with the given resolution. The width of every pixel is scale, while its height
+// depends on the aspect.
+func displayArea(scale, width, height int, a aspect) (int, int) {
+	if a == aspectSquare {
+		return scale * width, scale * width
+	}
+	return scale * width, scale * height
+}
+
+// windowSize returns the size of the display area of the window like
+// displayArea, and an error if scale is not positive or the window is too
+// large.
+func windowSize(scale, width, height int, a aspect) (int, int, error) {
+	if scale <= 0 {
+		return 0, 0, fmt.Errorf("invalid scale %d", scale)
+	}
+
+	w, h := displayArea(scale, width, height, a)
+
+	if w > maxWindowSize || h > maxWindowSize {
+		return 0, 0, fmt.Errorf("window too large for scale %d: %dx%d", scale, w, h)
+	}
+
+	return w, h, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestWindowSize(t *testing.T) {
+	tests := []struct {
+		width, height int
+		aspect        aspect
+		wantW, wantH  int
+	}{
+		{emulator.DisplayWidth, emulator.DisplayHeight, aspectNative, 640, 320},
+		{emulator.MaxDisplayWidth, emulator.MaxDisplayHeight, aspectNative, 1280, 640},
+		{emulator.DisplayWidth, emulator.DisplayHeight, aspectSquare, 640, 640},
+		{emulator.MaxDisplayWidth, emulator.MaxDisplayHeight, aspectSquare, 1280, 1280},
+	}
+
+	for _, tt := range tests {
+		w, h, err := windowSize(defaultScale, tt.width, tt.height, tt.aspect)
+		if err != nil {
+			t.Fatalf("window size: %v", err)
+		}
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("%dx%d %s: got %dx%d, want %dx%d", tt.width, tt.height, aspectNames[tt.aspect], w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestInvalidWindowSize(t *testing.T) {
+	for _, scale := range []int{-1, 0, 1000} {
+		if _, _, err := windowSize(scale, emulator.MaxDisplayWidth, emulator.MaxDisplayHeight, aspectNative); err == nil {
+			t.Errorf("expected error for scale %d", scale)
+		}
+	}
+}
+
+func TestParseAspect(t *testing.T) {
+	for _, name := range aspectNames {
+		if _, err := parseAspect(name); err != nil {
+			t.Errorf("parse %q: %v", name, err)
+		}
+	}
+
+	if _, err := parseAspect("4:3"); err == nil {
+		t.Error("expected error for unknown aspect")
+	}
+}
+
+func TestWindowTitle(t *testing.T) {
+	tests := []struct {
+		status titleStatus
+		want   string
+	}{
+		{titleStatus{}, "CHIP-8 Emulator"},
+		{titleStatus{rom: "pong.ch8", ips: 480}, "CHIP-8 Emulator - pong.ch8 (480 IPS)"},
+		{titleStatus{rom: "pong.ch8", variant: "schip", ips: 530, debug: true}, "CHIP-8 Emulator - pong.ch8 (schip, 530 IPS, debug)"},
+		{titleStatus{rom: "pong.ch8", ips: 480, halted: true}, "CHIP-8 Emulator - pong.ch8 (480 IPS, halted)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.status.title(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package debug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// Breakpoint stops the execution of a program when the machine state satisfies
+// a condition. Use [ParseBreakpoint] to create one.
+type Breakpoint struct {
+	text string
+	cond func(state *emulator.State) bool
+}
+
+// ParseBreakpoint parses a breakpoint. A breakpoint is either an address, in
+// hexadecimal, or a condition introduced by the keyword "when":
+//
+//	2ab
+//	when v3 == 5
+//	when pc >= 0x300 && dt == 0
+//
+// A condition compares the registers (v0 to vf, i, sp, pc, dt, and st) and
+// numbers with the operators ==, !=, <, <=, >, and >=. Comparisons can be
+// combined with && and ||, where && binds tighter than ||. Numbers are decimal,
+// unless they are prefixed by 0x.
+func ParseBreakpoint(s string) (*Breakpoint, error) {
+	s = strings.TrimSpace(s)
+
+	if expr, ok := strings.CutPrefix(s, "when "); ok {
+		cond, err := parseCondition(expr)
+		if err != nil {
+			return nil, err
+		}
+		return &Breakpoint{text: s, cond: cond}, nil
+	}
+
+	addr, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+
+	cond := func(state *emulator.State) bool {
+		return state.PC == uint16(addr)
+	}
+
+	return &Breakpoint{text: s, cond: cond}, nil
+}
+
+// Hit returns true if state satisfies the condition of the breakpoint.
+// Conditions only read the registers, the timers, and the program counter, so
+// state can be filled by [emulator.Emulator.CPUState].
+func (b *Breakpoint) Hit(state *emulator.State) bool {
+	return b.cond(state)
+}
+
+func (b *Breakpoint) String() string {
+	return b.text
+}
+
+func parseCondition(s string) (func(*emulator.State) bool, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens}
+
+	cond, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek())
+	}
+
+	return cond, nil
+}
+
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case isAlphanumeric(c):
+			j := i
+			for j < len(s) && isAlphanumeric(s[j]) {
+				j++
+			}
+			tokens = append(tokens, strings.ToLower(s[i:j]))
+			i = j
+		case strings.HasPrefix(s[i:], "=="), strings.HasPrefix(s[i:], "!="),
+			strings.HasPrefix(s[i:], "<="), strings.HasPrefix(s[i:], ">="),
+			strings.HasPrefix(s[i:], "&&"), strings.HasPrefix(s[i:], "||"):
+			tokens = append(tokens, s[i:i+2])
+			i += 2
+		case c == '<' || c == '>':
+			tokens = append(tokens, s[i:i+1])
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+
+	return tokens, nil
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parser is a recursive-descent parser for the conditions of a breakpoint.
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() (string, error) {
+	if p.done() {
+		return "", fmt.Errorf("unexpected end of condition")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+func (p *parser) or() (func(*emulator.State) bool, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == "||" {
+		p.pos++
+
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(s *emulator.State) bool { return l(s) || right(s) }
+	}
+
+	return left, nil
+}
+
+func (p *parser) and() (func(*emulator.State) bool, error) {
+	left, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == "&&" {
+		p.pos++
+
+		right, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(s *emulator.State) bool { return l(s) && right(s) }
+	}
+
+	return left, nil
+}
+
+func (p *parser) comparison() (func(*emulator.State) bool, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "==":
+		return func(s *emulator.State) bool { return left(s) == right(s) }, nil
+	case "!=":
+		return func(s *emulator.State) bool { return left(s) != right(s) }, nil
+	case "<":
+		return func(s *emulator.State) bool { return left(s) < right(s) }, nil
+	case "<=":
+		return func(s *emulator.State) bool { return left(s) <= right(s) }, nil
+	case ">":
+		return func(s *emulator.State) bool { return left(s) > right(s) }, nil
+	case ">=":
+		return func(s *emulator.State) bool { return left(s) >= right(s) }, nil
+	}
+
+	return nil, fmt.Errorf("invalid operator %q", op)
+}
+
+func (p *parser) operand() (func(*emulator.State) int, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t {
+	case "i":
+		return func(s *emulator.State) int { return int(s.I) }, nil
+	case "sp":
+		return func(s *emulator.State) int { return int(s.SP) }, nil
+	case "pc":
+		return func(s *emulator.State) int { return int(s.PC) }, nil
+	case "dt":
+		return func(s *emulator.State) int { return int(s.DT) }, nil
+	case "st":
+		return func(s *emulator.State) int { return int(s.ST) }, nil
+	}
+
+	if len(t) == 2 && t[0] == 'v' {
+		if r, err := strconv.ParseUint(t[1:], 16, 4); err == nil {
+			return func(s *emulator.State) int { return int(s.V[r]) }, nil
+		}
+	}
+
+	var n uint64
+
+	if hex, ok := strings.CutPrefix(t, "0x"); ok {
+		n, err = strconv.ParseUint(hex, 16, 16)
+	} else {
+		n, err = strconv.ParseUint(t, 10, 16)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid operand %q", t)
+	}
+
+	return func(*emulator.State) int { return int(n) }, nil
+}
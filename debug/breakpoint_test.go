@@ -0,0 +1,88 @@
+package debug_test
+
+import (
+	"testing"
+
+	"github.com/francescomari/chip-8/debug"
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestBreakpointHit(t *testing.T) {
+	var state emulator.State
+	state.V[3] = 5
+	state.PC = 0x2ab
+	state.DT = 0x10
+
+	tests := []struct {
+		breakpoint string
+		want       bool
+	}{
+		{"2ab", true},
+		{"0x2ac", false},
+		{"when v3 == 5", true},
+		{"when v3 != 5", false},
+		{"when V3>=5", true},
+		{"when pc < 0x2ab", false},
+		{"when pc == 0x2ab && dt > 16", false},
+		{"when pc == 0x2ab && dt <= 16", true},
+		{"when v0 == 1 || v3 == 5", true},
+		{"when v0 == 1 || v3 == 4 && pc == 0x2ab", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.breakpoint, func(t *testing.T) {
+			b, err := debug.ParseBreakpoint(tt.breakpoint)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got := b.Hit(&state); got != tt.want {
+				t.Fatalf("Hit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalidBreakpoint(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"zz",
+		"when",
+		"when v3",
+		"when v3 = 5",
+		"when vg == 5",
+		"when v3 == 5 &&",
+		"when v3 == 5 5",
+	} {
+		if _, err := debug.ParseBreakpoint(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestBreakpointStopsExecution(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0x73, 0x01, // ADD V3, 0x01
+		0x12, 0x00, // JP 0x200
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	b, err := debug.ParseBreakpoint("when v3 == 5")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if matched, _ := e.StepUntil(b.Hit, 100); !matched {
+		t.Fatal("breakpoint should be hit")
+	}
+
+	var state emulator.State
+
+	e.State(&state)
+
+	if state.V[3] != 5 {
+		t.Fatalf("v3 = %d, want 5", state.V[3])
+	}
+}
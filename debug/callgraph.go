@@ -0,0 +1,152 @@
+package debug
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// CallGraph returns the static call graph of rom, loaded at base. Every
+// subroutine, starting with the entry point at base, is mapped to the sorted
+// addresses of the subroutines it calls with CALL. A subroutine is made of the
+// instructions reachable from its first instruction without following CALL, up
+// to RET or HALT.
+//
+// The graph only follows direct jumps and skips. JP V0, addr jumps to an address
+// computed at runtime, so the code reachable only through it, and the calls in
+// that code, are not part of the graph. Self-modifying code is not followed
+// either.
+func CallGraph(rom []byte, base uint16) map[uint16][]uint16 {
+	graph := make(map[uint16][]uint16)
+
+	pending := []uint16{base}
+
+	for len(pending) > 0 {
+		entry := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if _, ok := graph[entry]; ok {
+			continue
+		}
+
+		calls := subroutineCalls(rom, base, entry)
+
+		graph[entry] = calls
+
+		pending = append(pending, calls...)
+	}
+
+	return graph
+}
+
+// subroutineCalls returns the sorted targets of the CALL instructions reachable
+// from entry.
+func subroutineCalls(rom []byte, base, entry uint16) []uint16 {
+	var (
+		visited = make(map[uint16]bool)
+		calls   = make(map[uint16]bool)
+		pending = []uint16{entry}
+	)
+
+	for len(pending) > 0 {
+		addr := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		i := int(addr) - int(base)
+
+		if i < 0 || i+1 >= len(rom) || visited[addr] {
+			continue
+		}
+
+		visited[addr] = true
+
+		op := uint16(rom[i])<<8 | uint16(rom[i+1])
+
+		if op&emulator.MaskFamily == emulator.OpTypeCALL {
+			calls[op&emulator.MaskNNN] = true
+		}
+
+		long := i+3 < len(rom) && uint16(rom[i+2])<<8|uint16(rom[i+3]) == emulator.OpTypeMisc|emulator.OpLDIL
+
+		pending = append(pending, flow(addr, op, long)...)
+	}
+
+	return slices.Sorted(maps.Keys(calls))
+}
+
+// flow returns the addresses of the instructions of the same subroutine that can
+// be executed after op, which is at addr. A CALL continues with the following
+// instruction, since the subroutine returns to it. LD I, long is four bytes
+// long, and a skip jumps over all of it, so skips need to know if the following
+// instruction, reported by long, is an LD I, long.
+func flow(addr, op uint16, long bool) []uint16 {
+
+	// Opcodes are decoded like the emulator does, so that the graph follows the
+	// instructions the emulator executes.
+
+	switch op & emulator.MaskFamily {
+	case emulator.OpTypeSys:
+		switch op & emulator.MaskKK {
+		case emulator.OpCLS, emulator.OpSCR, emulator.OpSCL, emulator.OpLOW, emulator.OpHIGH:
+			return []uint16{addr + 2}
+		}
+		switch op & emulator.MaskKK &^ emulator.MaskN {
+		case emulator.OpSCD, emulator.OpSCU:
+			return []uint16{addr + 2}
+		}
+		// RET, HALT, EXIT, and machine code routines end the flow.
+		return nil
+	case emulator.OpTypeJP:
+		return []uint16{op & emulator.MaskNNN}
+	case emulator.OpTypeSE, emulator.OpTypeSNE, emulator.OpTypeSEV, emulator.OpTypeSNEV, emulator.OpTypeKey:
+		if long {
+			return []uint16{addr + 2, addr + 6}
+		}
+		return []uint16{addr + 2, addr + 4}
+	case emulator.OpTypeJPV:
+		return nil
+	case emulator.OpTypeMisc:
+		if op&emulator.MaskKK == emulator.OpLDIL {
+			return []uint16{addr + 4}
+		}
+	}
+
+	return []uint16{addr + 2}
+}
+
+// PrintCallGraph writes one line for every subroutine of graph, in order of
+// address, followed by the subroutines it calls.
+func PrintCallGraph(w io.Writer, graph map[uint16][]uint16) {
+	out := printer(w)
+
+	for _, entry := range slices.Sorted(maps.Keys(graph)) {
+		out("%04x:", entry)
+		for _, callee := range graph[entry] {
+			out(" %04x", callee)
+		}
+		out("\n")
+	}
+}
+
+// PrintCallGraphDOT writes graph in the DOT language of Graphviz.
+func PrintCallGraphDOT(w io.Writer, graph map[uint16][]uint16) {
+	out := printer(w)
+
+	out("digraph calls {\n")
+
+	for _, entry := range slices.Sorted(maps.Keys(graph)) {
+		out("\t%s;\n", dotNode(entry))
+		for _, callee := range graph[entry] {
+			out("\t%s -> %s;\n", dotNode(entry), dotNode(callee))
+		}
+	}
+
+	out("}\n")
+}
+
+func dotNode(addr uint16) string {
+	return fmt.Sprintf("\"%04x\"", addr)
+}
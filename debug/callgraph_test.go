@@ -0,0 +1,134 @@
+package debug_test
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/francescomari/chip-8/debug"
+	"github.com/francescomari/chip-8/emulator"
+)
+
+var callGraphROM = []uint8{
+	0x22, 0x08, // 0200: CALL 0x208
+	0x22, 0x0c, // 0202: CALL 0x20c
+	0x12, 0x04, // 0204: JP 0x204
+	0xb2, 0x12, // 0206: JP V0, 0x212
+	0x22, 0x0c, // 0208: CALL 0x20c
+	0x00, 0xee, // 020a: RET
+	0x30, 0x01, // 020c: SE V0, 0x01
+	0x00, 0xee, // 020e: RET
+	0x00, 0xee, // 0210: RET
+	0x22, 0x16, // 0212: CALL 0x216, only reachable through JP V0, addr
+	0x00, 0xee, // 0214: RET
+	0x00, 0xee, // 0216: RET
+}
+
+func TestCallGraph(t *testing.T) {
+	graph := debug.CallGraph(callGraphROM, emulator.ProgramStart)
+
+	want := map[uint16][]uint16{
+		0x200: {0x208, 0x20c},
+		0x208: {0x20c},
+		0x20c: nil,
+	}
+
+	if !maps.EqualFunc(graph, want, slices.Equal) {
+		t.Fatalf("call graph: got %v, want %v", graph, want)
+	}
+}
+
+func TestCallGraphContinues(t *testing.T) {
+	// Each instruction is followed by a call, which is only part of the graph if
+	// the flow continues past the instruction.
+
+	for _, tt := range []struct {
+		name string
+		op   uint16
+	}{
+		{"cls", 0x00e0},
+		{"cls with x", 0x05e0}, // The emulator ignores the X nibble, like the graph
+		{"scd", 0x00c3},
+		{"scu", 0x00d3},
+		{"scr", 0x00fb},
+		{"scl", 0x00fc},
+		{"low", 0x00fe},
+		{"high", 0x00ff},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rom := []uint8{
+				uint8(tt.op >> 8), uint8(tt.op), // 0200: the instruction
+				0x22, 0x06, // 0202: CALL 0x206
+				0x00, 0x00, // 0204: HALT
+				0x00, 0xee, // 0206: RET
+			}
+
+			graph := debug.CallGraph(rom, emulator.ProgramStart)
+
+			want := map[uint16][]uint16{
+				0x200: {0x206},
+				0x206: nil,
+			}
+
+			if !maps.EqualFunc(graph, want, slices.Equal) {
+				t.Fatalf("call graph: got %v, want %v", graph, want)
+			}
+		})
+	}
+}
+
+func TestCallGraphLoadIndexLong(t *testing.T) {
+	// The address of LD I, long looks like a call, but it is never executed.
+	// The skip jumps over the whole LD I, long instruction.
+
+	rom := []uint8{
+		0x30, 0x01, // 0200: SE V0, 0x01
+		0xf0, 0x00, // 0202: LD I, long
+		0x22, 0x0a, // 0204: the address, not CALL 0x20a
+		0x22, 0x0c, // 0206: CALL 0x20c
+		0x00, 0x00, // 0208: HALT
+		0x00, 0xee, // 020a: RET
+		0x00, 0xee, // 020c: RET
+	}
+
+	graph := debug.CallGraph(rom, emulator.ProgramStart)
+
+	want := map[uint16][]uint16{
+		0x200: {0x20c},
+		0x20c: nil,
+	}
+
+	if !maps.EqualFunc(graph, want, slices.Equal) {
+		t.Fatalf("call graph: got %v, want %v", graph, want)
+	}
+}
+
+func TestPrintCallGraph(t *testing.T) {
+	graph := debug.CallGraph(callGraphROM, emulator.ProgramStart)
+
+	var b strings.Builder
+
+	debug.PrintCallGraph(&b, graph)
+
+	if want := "0200: 0208 020c\n0208: 020c\n020c:\n"; b.String() != want {
+		t.Fatalf("text: got %q, want %q", b.String(), want)
+	}
+
+	b.Reset()
+
+	debug.PrintCallGraphDOT(&b, graph)
+
+	want := "digraph calls {\n" +
+		"\t\"0200\";\n" +
+		"\t\"0200\" -> \"0208\";\n" +
+		"\t\"0200\" -> \"020c\";\n" +
+		"\t\"0208\";\n" +
+		"\t\"0208\" -> \"020c\";\n" +
+		"\t\"020c\";\n" +
+		"}\n"
+
+	if b.String() != want {
+		t.Fatalf("dot: got %q, want %q", b.String(), want)
+	}
+}
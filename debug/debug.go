@@ -47,6 +47,79 @@ func PrintInstruction(w io.Writer, state *emulator.State) {
 	out("%v", Instruction(state.Instruction()))
 }
 
+// PrintFont writes the sixteen glyphs of the font stored at the beginning of the
+// memory of state to w, side by side. Every glyph is rendered as an ASCII
+// bitmap, where '#' is a pixel that is on and '.' is a pixel that is off, under
+// a header showing the digit it represents.
+func PrintFont(w io.Writer, state *emulator.State) {
+	out := printer(w)
+
+	for digit := range 16 {
+		if digit > 0 {
+			out(" ")
+		}
+		out("%-4x", digit)
+	}
+
+	out("\n")
+
+	for row := range emulator.FontSize {
+		for digit := range 16 {
+			if digit > 0 {
+				out(" ")
+			}
+
+			b := state.Memory[digit*emulator.FontSize+row]
+
+			for bit := range 4 {
+				if b&(0x80>>bit) != 0 {
+					out("#")
+				} else {
+					out(".")
+				}
+			}
+		}
+
+		out("\n")
+	}
+}
+
+// brailleDots maps the position of a pixel in a 2×4 cell, indexed by row and
+// column, to the dot representing it in a Unicode braille pattern.
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// PrintBraille writes the active area of the display of state to w using
+// Unicode braille patterns. Every character represents a cell of 2×4 pixels, so
+// a 64×32 display is rendered as 8 lines of 32 characters.
+func PrintBraille(w io.Writer, state *emulator.State) {
+	out := printer(w)
+
+	for cy := 0; cy < state.Height; cy += 4 {
+		for cx := 0; cx < state.Width; cx += 2 {
+			pattern := rune(0x2800)
+
+			for dy := range 4 {
+				for dx := range 2 {
+					x, y := cx+dx, cy+dy
+
+					if x < state.Width && y < state.Height && state.Display[y][x] != 0 {
+						pattern |= brailleDots[dy][dx]
+					}
+				}
+			}
+
+			out("%c", pattern)
+		}
+
+		out("\n")
+	}
+}
+
 // Instruction wraps a raw instruction from the emulator's state and returns a
 // printable representation of the opcode and its arguments.
 type Instruction uint16
@@ -68,6 +141,23 @@ func (i Instruction) String() string {
 			return "cls"
 		case emulator.OpRET:
 			return "ret"
+		case emulator.OpSCR:
+			return "scr"
+		case emulator.OpSCL:
+			return "scl"
+		case emulator.OpEXIT:
+			return "exit"
+		case emulator.OpLOW:
+			return "low"
+		case emulator.OpHIGH:
+			return "high"
+		}
+
+		switch op & emulator.MaskKK &^ emulator.MaskN {
+		case emulator.OpSCD:
+			return fmt.Sprintf("scd %s", b)
+		case emulator.OpSCU:
+			return fmt.Sprintf("scu %s", b)
 		}
 	case emulator.OpTypeJP:
 		return fmt.Sprintf("jp %s", n)
@@ -123,6 +213,12 @@ func (i Instruction) String() string {
 		}
 	case emulator.OpTypeMisc:
 		switch op & emulator.MaskKK {
+		case emulator.OpLDIL:
+			return "ld i, long"
+		case emulator.OpPLANE:
+			return fmt.Sprintf("plane %d", (op&emulator.MaskX)>>emulator.ShiftX)
+		case emulator.OpAUDIO:
+			return "audio"
 		case emulator.OpLDVDT:
 			return fmt.Sprintf("ld %s, dt", x)
 		case emulator.OpLDVK:
@@ -135,10 +231,18 @@ func (i Instruction) String() string {
 			return fmt.Sprintf("add i, %s", x)
 		case emulator.OpLDF:
 			return fmt.Sprintf("ld f, %s", x)
+		case emulator.OpLDHF:
+			return fmt.Sprintf("ld hf, %s", x)
+		case emulator.OpPITCH:
+			return fmt.Sprintf("pitch %s", x)
 		case emulator.OpLDB:
 			return fmt.Sprintf("ld b, %s", x)
 		case emulator.OpSTMV:
 			return fmt.Sprintf("ld [i], %s", x)
+		case emulator.OpLDRV:
+			return fmt.Sprintf("ld r, %s", x)
+		case emulator.OpLDVR:
+			return fmt.Sprintf("ld %s, r", x)
 		case emulator.OpLDVM:
 			return fmt.Sprintf("ld %s, [i]", x)
 		}
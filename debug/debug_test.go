@@ -16,6 +16,13 @@ func TestInstructionString(t *testing.T) {
 		// Sys
 		{emulator.OpCLS, "cls"},
 		{emulator.OpRET, "ret"},
+		{0x00c3, "scd 3"},
+		{0x00d3, "scu 3"},
+		{emulator.OpSCR, "scr"},
+		{emulator.OpSCL, "scl"},
+		{emulator.OpEXIT, "exit"},
+		{emulator.OpLOW, "low"},
+		{emulator.OpHIGH, "high"},
 
 		// JP, CALL
 		{0x1234, "jp 234"},
@@ -60,10 +67,17 @@ func TestInstructionString(t *testing.T) {
 		{0xf115, "ld dt, v1"},
 		{0xf118, "ld st, v1"},
 		{0xf11e, "add i, v1"},
+		{0xf000, "ld i, long"},
+		{0xf201, "plane 2"},
+		{0xf002, "audio"},
+		{0xf43a, "pitch v4"},
 		{0xf129, "ld f, v1"},
+		{0xf230, "ld hf, v2"},
 		{0xf133, "ld b, v1"},
 		{0xf155, "ld [i], v1"},
 		{0xf165, "ld v1, [i]"},
+		{0xf275, "ld r, v2"},
+		{0xf285, "ld v2, r"},
 
 		// Unknown
 		{0x8009, "unknown (8009)"},
@@ -137,3 +151,66 @@ func TestPrintInstruction(t *testing.T) {
 		t.Errorf("PrintInstruction = %q, want %q", got, "cls")
 	}
 }
+
+func TestPrintFont(t *testing.T) {
+	e := emulator.New()
+
+	var state emulator.State
+
+	e.State(&state)
+
+	var b strings.Builder
+	debug.PrintFont(&b, &state)
+
+	lines := strings.Split(b.String(), "\n")
+
+	if len(lines) < 6 {
+		t.Fatalf("expected a header and five rows, got %q", b.String())
+	}
+
+	if got := lines[0][:4]; got != "0   " {
+		t.Errorf("header = %q, want %q", got, "0   ")
+	}
+
+	want := []string{"####", "#..#", "#..#", "#..#", "####"}
+
+	for i, row := range want {
+		if got := lines[i+1][:4]; got != row {
+			t.Errorf("row %d of glyph 0 = %q, want %q", i, got, row)
+		}
+	}
+}
+
+func TestPrintBraille(t *testing.T) {
+	var state emulator.State
+	state.Width = emulator.DisplayWidth
+	state.Height = emulator.DisplayHeight
+	state.Display[0][0] = 1
+	state.Display[3][1] = 1
+	state.Display[31][63] = 1
+
+	var b strings.Builder
+	debug.PrintBraille(&b, &state)
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+
+	if len(lines) != 8 {
+		t.Fatalf("lines = %d, want 8", len(lines))
+	}
+
+	first := []rune(lines[0])
+	last := []rune(lines[7])
+
+	if len(first) != 32 {
+		t.Fatalf("characters per line = %d, want 32", len(first))
+	}
+	if first[0] != '\u2881' {
+		t.Errorf("first cell = %U, want U+2881", first[0])
+	}
+	if first[1] != '\u2800' {
+		t.Errorf("second cell = %U, want U+2800", first[1])
+	}
+	if last[31] != '\u2880' {
+		t.Errorf("last cell = %U, want U+2880", last[31])
+	}
+}
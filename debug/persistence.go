@@ -0,0 +1,69 @@
+package debug
+
+import (
+	"io"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// Gradient lists the glyphs used by [PrintDisplay], from a pixel that is off to
+// a pixel that is fully on.
+const Gradient = " .:-=+*#"
+
+// Persistence simulates the phosphor of a CRT, which keeps glowing for a while
+// after a pixel is turned off. It keeps the intensity of every pixel, from 0 to
+// 255: a pixel that is on has the maximum intensity, while a pixel that is off
+// fades out a bit in every frame. Programs flicker much less when rendered with
+// their intensity, and moving sprites leave a trail behind them.
+type Persistence struct {
+	decay     uint8
+	intensity [emulator.MaxDisplayHeight][emulator.MaxDisplayWidth]uint8
+}
+
+// NewPersistence returns a buffer where pixels that are off lose decay units of
+// intensity in every frame.
+func NewPersistence(decay uint8) *Persistence {
+	return &Persistence{decay: decay}
+}
+
+// Update must be called at the end of every frame, with the display of state.
+func (p *Persistence) Update(state *emulator.State) {
+	for y := range state.Height {
+		for x := range state.Width {
+			if state.Display[y][x] != 0 {
+				p.intensity[y][x] = 0xff
+			} else {
+				p.intensity[y][x] -= min(p.intensity[y][x], p.decay)
+			}
+		}
+	}
+}
+
+// Intensity returns the intensity of the pixel at (x, y).
+func (p *Persistence) Intensity(x, y int) uint8 {
+	return p.intensity[y][x]
+}
+
+// PrintDisplay writes the active area of the display of state to w, one line
+// for every row. If p is nil, every pixel is either the first glyph of
+// [Gradient], if it is off, or the last one, if it is on. Otherwise, every pixel
+// is the glyph of Gradient matching its intensity in p.
+func PrintDisplay(w io.Writer, state *emulator.State, p *Persistence) {
+	out := printer(w)
+
+	for y := range state.Height {
+		for x := range state.Width {
+			var intensity uint8
+
+			if p != nil {
+				intensity = p.Intensity(x, y)
+			} else if state.Display[y][x] != 0 {
+				intensity = 0xff
+			}
+
+			out("%c", Gradient[int(intensity)*(len(Gradient)-1)/0xff])
+		}
+
+		out("\n")
+	}
+}
@@ -0,0 +1,65 @@
+package debug_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/francescomari/chip-8/debug"
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestPrintDisplay(t *testing.T) {
+	var state emulator.State
+	state.Width = emulator.DisplayWidth
+	state.Height = emulator.DisplayHeight
+	state.Display[0][0] = 1
+	state.Display[31][63] = 1
+
+	var b strings.Builder
+	debug.PrintDisplay(&b, &state, nil)
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+
+	if len(lines) != 32 || len(lines[0]) != 64 {
+		t.Fatalf("size = %dx%d, want 64x32", len(lines[0]), len(lines))
+	}
+	if got := lines[0][:2]; got != "# " {
+		t.Errorf("first pixels = %q, want %q", got, "# ")
+	}
+	if got := lines[31][62:]; got != " #" {
+		t.Errorf("last pixels = %q, want %q", got, " #")
+	}
+}
+
+func TestPrintDisplayPersistence(t *testing.T) {
+	var state emulator.State
+	state.Width = emulator.DisplayWidth
+	state.Height = emulator.DisplayHeight
+
+	p := debug.NewPersistence(0x40)
+
+	// A pixel moves one step to the right in every frame, leaving a trail that
+	// fades out over four frames.
+
+	for x := range 5 {
+		if x > 0 {
+			state.Display[0][x-1] = 0
+		}
+		state.Display[0][x] = 1
+		p.Update(&state)
+	}
+
+	if got := p.Intensity(4, 0); got != 0xff {
+		t.Errorf("intensity of the pixel that is on = %02x, want ff", got)
+	}
+	if got := p.Intensity(3, 0); got != 0xbf {
+		t.Errorf("intensity after a frame = %02x, want bf", got)
+	}
+
+	var b strings.Builder
+	debug.PrintDisplay(&b, &state, p)
+
+	if got, want := strings.SplitN(b.String(), "\n", 2)[0][:6], " .-+# "; got != want {
+		t.Errorf("first pixels = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package debug
+
+import (
+	"fmt"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// SelfModification is an instruction executed after the program overwrote it.
+type SelfModification struct {
+	Addr uint16      // Address of the instruction
+	Old  Instruction // Instruction before it was overwritten
+	New  Instruction // Instruction about to be executed
+}
+
+func (m SelfModification) String() string {
+	return fmt.Sprintf("%04x %v -> %v", m.Addr, m.Old, m.New)
+}
+
+// SelfModTracer detects instructions that are executed after being overwritten
+// by the program itself. It watches the writes to a range of memory, usually
+// the program, and compares the instruction about to be executed with the one
+// that was there before it was overwritten.
+type SelfModTracer struct {
+	start, end uint16
+	memory     emulator.Memory              // Memory as of the last time it was executed
+	current    emulator.Memory              // Memory as of the last write
+	written    [len(emulator.Memory{})]bool // Bytes written since the last time they were executed
+}
+
+// NewSelfModTracer returns a tracer watching the writes to memory in [start,
+// end). It registers a memory tracer on e, replacing any other, and should be
+// created after the program is loaded, since the memory of e at that moment is
+// considered unmodified.
+func NewSelfModTracer(e *emulator.Emulator, start, end uint16) *SelfModTracer {
+	var state emulator.State
+
+	e.State(&state)
+
+	t := SelfModTracer{
+		start:  start,
+		end:    end,
+		memory: state.Memory,
+	}
+
+	e.SetMemTracer(t.trace)
+
+	return &t
+}
+
+func (t *SelfModTracer) trace(addr uint16, write bool, value uint8) {
+	if write && addr >= t.start && addr < t.end {
+		t.written[addr] = true
+		t.current[addr] = value
+	}
+}
+
+// Check must be called before the instruction at pc is executed. It returns the
+// instruction before and after the change if any of its bytes has been
+// overwritten since it was last executed, or since the tracer was created.
+// Every change is reported only once. The tracer knows the bytes written by the
+// program, so the memory doesn't need to be copied out of the emulator before
+// every instruction.
+func (t *SelfModTracer) Check(pc uint16) (SelfModification, bool) {
+	i := int(pc)
+
+	if i+1 >= len(t.memory) || (!t.written[i] && !t.written[i+1]) {
+		return SelfModification{}, false
+	}
+
+	hi, lo := t.byteAt(i), t.byteAt(i+1)
+
+	m := SelfModification{
+		Addr: pc,
+		Old:  Instruction(uint16(t.memory[i])<<8 | uint16(t.memory[i+1])),
+		New:  Instruction(uint16(hi)<<8 | uint16(lo)),
+	}
+
+	t.written[i], t.written[i+1] = false, false
+	t.memory[i], t.memory[i+1] = hi, lo
+
+	return m, true
+}
+
+// byteAt returns the byte at addr as of the last write, or as of the last time
+// it was executed if it wasn't written since.
+func (t *SelfModTracer) byteAt(addr int) uint8 {
+	if t.written[addr] {
+		return t.current[addr]
+	}
+	return t.memory[addr]
+}
@@ -0,0 +1,63 @@
+package debug_test
+
+import (
+	"testing"
+
+	"github.com/francescomari/chip-8/debug"
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestSelfModTracer(t *testing.T) {
+	program := []uint8{
+		0x60, 0x71, // LD V0, 0x71
+		0x61, 0x05, // LD V1, 0x05
+		0xa2, 0x0a, // LD I, 0x20a
+		0xf1, 0x55, // LD [I], V1
+		0x62, 0x01, // LD V2, 0x01
+		0x00, 0x00, // HALT, overwritten with ADD V1, 0x05
+		0x00, 0x00, // HALT
+	}
+
+	e := emulator.New()
+
+	if err := e.Load(program); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	tracer := debug.NewSelfModTracer(e, emulator.ProgramStart, emulator.ProgramStart+uint16(len(program)))
+
+	var (
+		state emulator.State
+		mods  []debug.SelfModification
+	)
+
+	for {
+		e.CPUState(&state)
+
+		if m, ok := tracer.Check(state.PC); ok {
+			mods = append(mods, m)
+		}
+
+		ok, err := e.Step()
+		if err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		if !ok {
+			break
+		}
+	}
+
+	want := debug.SelfModification{
+		Addr: 0x20a,
+		Old:  0x0000,
+		New:  0x7105,
+	}
+
+	if len(mods) != 1 || mods[0] != want {
+		t.Fatalf("self-modifications: got %v, want [%v]", mods, want)
+	}
+
+	if got := want.String(); got != "020a unknown (0000) -> add v1, 05" {
+		t.Fatalf("string: got %q", got)
+	}
+}
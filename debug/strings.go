@@ -0,0 +1,48 @@
+package debug
+
+import (
+	"fmt"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// EmbeddedString is a run of printable ASCII characters found in a rom.
+type EmbeddedString struct {
+	Addr uint16 // Address of the first character, once the rom is loaded
+	Text string
+}
+
+func (s EmbeddedString) String() string {
+	return fmt.Sprintf("%04x %q", s.Addr, s.Text)
+}
+
+// FindStrings returns the runs of at least minLen printable ASCII characters in
+// rom, like titles and credits embedded by the author, in the order they appear.
+// Addresses assume that rom is loaded at [emulator.ProgramStart].
+//
+// Sprites and instructions can look like text by chance, especially for small
+// values of minLen, so the results need to be confirmed by looking at how the
+// program uses them.
+func FindStrings(rom []byte, minLen int) []EmbeddedString {
+	var (
+		found []EmbeddedString
+		start int
+	)
+
+	for i := 0; i <= len(rom); i++ {
+		if i < len(rom) && rom[i] >= 0x20 && rom[i] < 0x7f {
+			continue
+		}
+
+		if i-start >= max(minLen, 1) {
+			found = append(found, EmbeddedString{
+				Addr: emulator.ProgramStart + uint16(start),
+				Text: string(rom[start:i]),
+			})
+		}
+
+		start = i + 1
+	}
+
+	return found
+}
@@ -0,0 +1,30 @@
+package debug_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/francescomari/chip-8/debug"
+)
+
+func TestFindStrings(t *testing.T) {
+	rom := []byte{
+		0x12, 0x0a, // 0200: JP 0x20a
+		'P', 'O', 'N', 'G', 0x00, // 0202: "PONG"
+		'b', 'y', 0x00, // 0207: "by", too short
+		0x60, 0x01, // 020a: LD V0, 0x01
+		0x12, 0x0a, // 020c: JP 0x20a
+		'(', 'c', ')', ' ', '1', '9', '9', '0', // 020e: "(c) 1990", at the end of the rom
+	}
+
+	got := debug.FindStrings(rom, 3)
+
+	want := []debug.EmbeddedString{
+		{Addr: 0x202, Text: "PONG"},
+		{Addr: 0x20e, Text: "(c) 1990"},
+	}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
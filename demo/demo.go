@@ -0,0 +1,262 @@
+// Package demo records and replays CHIP-8 sessions.
+//
+// A demo contains the hash of the rom, the seed of the random number generator,
+// the quirks, the instructions executed per frame, and the key events of every
+// frame. Replaying a demo runs the emulator in lockstep, as described by
+// [emulator.Emulator.StepFrameWithInput], so it reproduces the recorded session
+// exactly.
+//
+// Demos are text files. The header is followed by one line for every frame with
+// key events, listing the frame number and the events, where +k presses the key
+// k and -k releases it:
+//
+//	chip8demo 1
+//	rom b9bbc12cee3f7b9d3b1f69161f7d7a2d86953379 132
+//	seed 1 2
+//	quirks shift-vy,mem-inc,jump-v0,display-nowait
+//	steps 8
+//	frames 120
+//	12 +5
+//	15 -5
+//
+// Input logs, which record keyboard keys rather than keys of the keypad, can be
+// read with [ReadInputLog], and replayed with [Replay] or saved as a demo with
+// [Write].
+package demo
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+const version = 1
+
+// maxFrames bounds the frames of a demo or an input log, about three days at 60
+// frames per second, so that a corrupt file can't make [Play] run forever or
+// exhaust the memory.
+const maxFrames = 1 << 24
+
+// Meta describes the session recorded in a demo.
+type Meta struct {
+	ROMHash       [sha1.Size]byte // SHA-1 hash of the rom
+	ROMSize       int             // Size of the rom in bytes
+	Seed          [2]uint64       // Seed of the random number generator, see NewRNG
+	Quirks        emulator.Quirks // Quirks of the emulator
+	StepsPerFrame int             // Instructions executed in every frame
+}
+
+// NewMeta returns the description of a session running rom.
+func NewMeta(rom []byte, seed [2]uint64, quirks emulator.Quirks, stepsPerFrame int) Meta {
+	return Meta{
+		ROMHash:       sha1.Sum(rom),
+		ROMSize:       len(rom),
+		Seed:          seed,
+		Quirks:        quirks,
+		StepsPerFrame: stepsPerFrame,
+	}
+}
+
+// NewRNG returns the random number generator used to replay a demo recorded
+// with the given seed. A session must use it to be recorded.
+func NewRNG(seed [2]uint64) func() uint32 {
+	return rand.New(rand.NewPCG(seed[0], seed[1])).Uint32
+}
+
+// Write writes a demo to w. The events of the i-th frame are in events[i].
+func Write(w io.Writer, meta Meta, events [][]emulator.KeyEvent) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "chip8demo %d\n", version)
+	fmt.Fprintf(bw, "rom %x %d\n", meta.ROMHash, meta.ROMSize)
+	fmt.Fprintf(bw, "seed %d %d\n", meta.Seed[0], meta.Seed[1])
+	fmt.Fprintf(bw, "quirks %s\n", strings.Join(meta.Quirks.Names(), ","))
+	fmt.Fprintf(bw, "steps %d\n", meta.StepsPerFrame)
+	fmt.Fprintf(bw, "frames %d\n", len(events))
+
+	for frame, frameEvents := range events {
+		if len(frameEvents) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(bw, "%d", frame)
+
+		for _, event := range frameEvents {
+			if event.Down {
+				fmt.Fprintf(bw, " +%x", event.Key)
+			} else {
+				fmt.Fprintf(bw, " -%x", event.Key)
+			}
+		}
+
+		fmt.Fprintf(bw, "\n")
+	}
+
+	return bw.Flush()
+}
+
+// Play replays the demo read from r on e, which must have loaded the rom the
+// demo was recorded with. The emulator is reset, configured as described by
+// the demo, and run until the last frame. It returns an error if the rom doesn't
+// match the demo, if the demo is invalid, or if the program fails.
+func Play(e *emulator.Emulator, r io.Reader) error {
+	s := bufio.NewScanner(r)
+
+	meta, frames, err := readHeader(s)
+	if err != nil {
+		return err
+	}
+
+	e.Reset()
+
+	var state emulator.State
+
+	e.State(&state)
+
+	if end := emulator.ProgramStart + meta.ROMSize; end < emulator.ProgramStart || end > len(state.Memory) || sha1.Sum(state.Memory[emulator.ProgramStart:end]) != meta.ROMHash {
+		return fmt.Errorf("the loaded rom doesn't match the demo")
+	}
+
+	e.SetQuirks(meta.Quirks)
+	e.SetRNG(NewRNG(meta.Seed))
+
+	if err := e.SetStepsPerFrame(meta.StepsPerFrame); err != nil {
+		return err
+	}
+
+	events := make(map[uint64][]emulator.KeyEvent)
+
+	for s.Scan() {
+		frame, frameEvents, err := parseFrame(s.Text())
+		if err != nil {
+			return err
+		}
+		if _, ok := events[frame]; ok || frame >= frames {
+			return fmt.Errorf("invalid frame %d", frame)
+		}
+		events[frame] = frameEvents
+	}
+
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("read demo: %v", err)
+	}
+
+	for frame := range frames {
+		ok, err := e.StepFrameWithInput(frame, events[frame])
+		if err != nil {
+			return fmt.Errorf("frame %d: %v", frame, err)
+		}
+		if !ok {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Replay runs a frame of e in lockstep mode for every element of events, which
+// holds the key events of the frame. The emulator must be at the first frame,
+// like after [emulator.Emulator.Load] or [emulator.Emulator.Reset], and
+// configured by the caller. It stops early if the program halts, and returns an
+// error if the program fails.
+func Replay(e *emulator.Emulator, events [][]emulator.KeyEvent) error {
+	for frame, frameEvents := range events {
+		ok, err := e.StepFrameWithInput(uint64(frame), frameEvents)
+		if err != nil {
+			return fmt.Errorf("frame %d: %v", frame, err)
+		}
+		if !ok {
+			break
+		}
+	}
+
+	return nil
+}
+
+func readHeader(s *bufio.Scanner) (Meta, uint64, error) {
+	var (
+		meta   Meta
+		frames uint64
+		v      int
+		hash   string
+		quirks string
+	)
+
+	fields := []struct {
+		format string
+		args   []any
+	}{
+		{"chip8demo %d", []any{&v}},
+		{"rom %s %d", []any{&hash, &meta.ROMSize}},
+		{"seed %d %d", []any{&meta.Seed[0], &meta.Seed[1]}},
+		{"quirks %s", []any{&quirks}},
+		{"steps %d", []any{&meta.StepsPerFrame}},
+		{"frames %d", []any{&frames}},
+	}
+
+	for _, f := range fields {
+		if !s.Scan() {
+			return meta, 0, fmt.Errorf("truncated header")
+		}
+		if _, err := fmt.Sscanf(s.Text(), f.format, f.args...); err != nil {
+			return meta, 0, fmt.Errorf("invalid header line %q: %v", s.Text(), err)
+		}
+	}
+
+	if v != version {
+		return meta, 0, fmt.Errorf("unsupported version %d", v)
+	}
+
+	if n, err := hex.Decode(meta.ROMHash[:], []byte(hash)); err != nil || n != sha1.Size {
+		return meta, 0, fmt.Errorf("invalid rom hash %q", hash)
+	}
+
+	if meta.ROMSize < 0 {
+		return meta, 0, fmt.Errorf("invalid rom size %d", meta.ROMSize)
+	}
+
+	if frames > maxFrames {
+		return meta, 0, fmt.Errorf("too many frames %d (max %d)", frames, maxFrames)
+	}
+
+	q, err := emulator.ParseQuirks(quirks, emulator.DefaultQuirks())
+	if err != nil {
+		return meta, 0, err
+	}
+
+	meta.Quirks = q
+
+	return meta, frames, nil
+}
+
+func parseFrame(line string) (uint64, []emulator.KeyEvent, error) {
+	fields := strings.Fields(line)
+
+	if len(fields) < 2 {
+		return 0, nil, fmt.Errorf("invalid frame %q", line)
+	}
+
+	frame, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid frame %q", line)
+	}
+
+	var events []emulator.KeyEvent
+
+	for _, f := range fields[1:] {
+		key, err := strconv.ParseUint(f[1:], 16, 4)
+		if err != nil || (f[0] != '+' && f[0] != '-') {
+			return 0, nil, fmt.Errorf("invalid event %q in frame %d", f, frame)
+		}
+		events = append(events, emulator.KeyEvent{Down: f[0] == '+', Key: uint8(key)})
+	}
+
+	return frame, events, nil
+}
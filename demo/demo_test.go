@@ -0,0 +1,127 @@
+package demo_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/francescomari/chip-8/demo"
+	"github.com/francescomari/chip-8/emulator"
+)
+
+var program = []uint8{
+	0xc0, 0x3f, // RND V0, 0x3f
+	0xc1, 0x1f, // RND V1, 0x1f
+	0xf2, 0x29, // LD F, V2
+	0xd0, 0x15, // DRW V0, V1, 0x05
+	0xf2, 0x0a, // LD V2, K
+	0x12, 0x00, // JP 0x200
+}
+
+func TestRoundTrip(t *testing.T) {
+	seed := [2]uint64{7, 11}
+
+	quirks := emulator.VariantSCHIP.Quirks()
+
+	// Record a scripted session, where a key is tapped every ten frames.
+
+	e := emulator.New()
+	e.SetQuirks(quirks)
+	e.SetRNG(demo.NewRNG(seed))
+
+	if err := e.Load(program); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	events := make([][]emulator.KeyEvent, 200)
+
+	for frame := range events {
+		switch frame % 10 {
+		case 3:
+			events[frame] = []emulator.KeyEvent{{Down: true, Key: uint8(frame % 16)}}
+		case 4:
+			events[frame] = []emulator.KeyEvent{{Down: false, Key: uint8((frame - 1) % 16)}}
+		}
+
+		if _, err := e.StepFrameWithInput(uint64(frame), events[frame]); err != nil {
+			t.Fatalf("frame %d: %v", frame, err)
+		}
+	}
+
+	var b bytes.Buffer
+
+	if err := demo.Write(&b, demo.NewMeta(program, seed, quirks, emulator.DefaultStepsPerFrame), events); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// Replay the session on a fresh emulator, with the default configuration.
+
+	replay := emulator.New()
+
+	if err := replay.Load(program); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if err := demo.Play(replay, &b); err != nil {
+		t.Fatalf("play: %v", err)
+	}
+
+	if replay.StateHash() != e.StateHash() {
+		t.Fatal("replay diverged from the recorded session")
+	}
+}
+
+func TestPlayTooManyFrames(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := demo.Write(&b, demo.NewMeta(program, [2]uint64{}, emulator.DefaultQuirks(), emulator.DefaultStepsPerFrame), nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// The program never halts, so it would run for as many frames as the header
+	// claims.
+
+	s := strings.Replace(b.String(), "frames 0", "frames 18446744073709551615", 1)
+
+	e := emulator.New()
+
+	if err := e.Load(program); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if err := demo.Play(e, strings.NewReader(s)); err == nil {
+		t.Fatal("expected error for too many frames")
+	}
+}
+
+func TestPlayWrongROM(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := demo.Write(&b, demo.NewMeta(program, [2]uint64{}, emulator.DefaultQuirks(), emulator.DefaultStepsPerFrame), nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	e := emulator.New()
+
+	if err := e.Load(program[:len(program)-2]); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if err := demo.Play(e, &b); err == nil {
+		t.Fatal("expected error for a different rom")
+	}
+}
+
+func TestPlayInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"chip8demo 2\n",
+		"chip8demo 1\nrom zz 12\n",
+		"chip8demo 1\nrom b9bbc12cee3f7b9d3b1f69161f7d7a2d86953379 -1\nseed 1 2\nquirks shift-vy\nsteps 8\nframes 1\n",
+		"chip8demo 1\nrom b9bbc12cee3f7b9d3b1f69161f7d7a2d86953379 132\nseed 1 2\nquirks shift-vz\n",
+	} {
+		if err := demo.Play(emulator.New(), bytes.NewBufferString(s)); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package demo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// keyboardKeys maps the keyboard keys named by an input log to the keys of the
+// keypad. The mapping is the default keymap of Octo: besides the usual 4×4
+// block on the left of the keyboard, it maps the arrow keys to the directions
+// most programs use, and the space bar to 6.
+var keyboardKeys = map[string]uint8{
+	"x": 0x0, "1": 0x1, "2": 0x2, "3": 0x3,
+	"q": 0x4, "w": 0x5, "e": 0x6, "a": 0x7,
+	"s": 0x8, "d": 0x9, "z": 0xa, "c": 0xb,
+	"4": 0xc, "r": 0xd, "f": 0xe, "v": 0xf,
+
+	"arrowup":    0x5,
+	"arrowleft":  0x7,
+	"arrowdown":  0x8,
+	"arrowright": 0x9,
+	"space":      0x6,
+}
+
+// ReadInputLog reads an input log, and returns the key events of every frame,
+// in the form accepted by [Write] and [Replay]. Unlike a demo, an input log
+// records keyboard keys rather than keys of the keypad, so that it is easy to
+// write by hand or with a script driving the keyboard.
+//
+// Every line of the log describes a keyboard event, with the frame it happened
+// in, the type of the event, either keydown or keyup, and the key, as named by
+// the browser, except for the space bar, which is named Space. Keys are
+// translated to the keypad with the default keymap of Octo. Events for keys
+// outside of the keymap are ignored. Empty lines and lines starting with # are
+// ignored:
+//
+//	# frame event key
+//	12 keydown w
+//	15 keyup w
+//	40 keydown ArrowLeft
+//
+// The events of a frame are queued in the order they appear in the log, at the
+// beginning of the frame, so the program sees them after the instructions of
+// the previous frame.
+func ReadInputLog(r io.Reader) ([][]emulator.KeyEvent, error) {
+	var events [][]emulator.KeyEvent
+
+	s := bufio.NewScanner(r)
+
+	for n := 1; s.Scan(); n++ {
+		fields := strings.Fields(s.Text())
+
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: invalid event %q", n, s.Text())
+		}
+
+		frame, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil || frame >= maxFrames {
+			return nil, fmt.Errorf("line %d: invalid frame %q", n, fields[0])
+		}
+
+		var down bool
+
+		switch fields[1] {
+		case "keydown":
+			down = true
+		case "keyup":
+			down = false
+		default:
+			return nil, fmt.Errorf("line %d: invalid event type %q", n, fields[1])
+		}
+
+		key, ok := keyboardKeys[strings.ToLower(fields[2])]
+		if !ok {
+			continue
+		}
+
+		for uint64(len(events)) <= frame {
+			events = append(events, nil)
+		}
+
+		events[frame] = append(events[frame], emulator.KeyEvent{Down: down, Key: key})
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("read input log: %v", err)
+	}
+
+	return events, nil
+}
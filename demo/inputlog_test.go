@@ -0,0 +1,109 @@
+package demo_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/francescomari/chip-8/demo"
+	"github.com/francescomari/chip-8/emulator"
+)
+
+const inputLog = `# frame event key
+3 keydown W
+5 keyup w
+5 keydown Shift
+8 keydown ArrowDown
+9 keyup ArrowDown
+`
+
+func TestReadInputLog(t *testing.T) {
+	events, err := demo.ReadInputLog(strings.NewReader(inputLog))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	// Shift is not in the keymap, so its event is dropped.
+
+	want := [][]emulator.KeyEvent{
+		3: {{Down: true, Key: 0x5}},
+		5: {{Down: false, Key: 0x5}},
+		8: {{Down: true, Key: 0x8}},
+		9: {{Down: false, Key: 0x8}},
+	}
+
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events: got %v, want %v", events, want)
+	}
+}
+
+func TestReadInputLogInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+	}{
+		{"missing key", "3 keydown\n"},
+		{"invalid frame", "x keydown w\n"},
+		{"invalid event", "3 keypress w\n"},
+		{"frame too large", "100000000000 keydown w\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := demo.ReadInputLog(strings.NewReader(test.log)); err == nil {
+				t.Fatalf("no error")
+			}
+		})
+	}
+}
+
+func TestReplayInputLog(t *testing.T) {
+	events, err := demo.ReadInputLog(strings.NewReader(inputLog))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	program := []uint8{
+		0xf0, 0x0a, // LD V0, K
+		0x71, 0x01, // ADD V1, 0x01
+		0x12, 0x00, // JP 0x200
+	}
+
+	// Replaying a prefix of the log shows in which frame the program sees every
+	// key. LD V0, K completes when the key is released, at the beginning of the
+	// frame of the keyup event.
+
+	tests := []struct {
+		frames int
+		v0, v1 uint8
+	}{
+		{frames: 5, v0: 0x0, v1: 0},
+		{frames: 6, v0: 0x5, v1: 1},
+		{frames: 9, v0: 0x5, v1: 1},
+		{frames: 10, v0: 0x8, v1: 2},
+	}
+
+	for _, test := range tests {
+		e := emulator.New()
+
+		if err := e.Load(program); err != nil {
+			t.Fatalf("load: %v", err)
+		}
+
+		if err := demo.Replay(e, events[:test.frames]); err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+
+		var state emulator.State
+
+		e.State(&state)
+
+		if state.FrameCount != uint64(test.frames) {
+			t.Errorf("%d frames: frame count: got %d", test.frames, state.FrameCount)
+		}
+
+		if state.V[0] != test.v0 || state.V[1] != test.v1 {
+			t.Errorf("%d frames: got v0=%x v1=%d, want v0=%x v1=%d", test.frames, state.V[0], state.V[1], test.v0, test.v1)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package emulator
+
+import (
+	"fmt"
+	"sync"
+)
+
+// frameBuffer holds the display as it was at the end of the last frame, packed
+// like [Emulator.DisplayInto]. It is guarded by a mutex, so that it can be read
+// while the emulator runs on a different goroutine.
+type frameBuffer struct {
+	mu      sync.Mutex
+	enabled bool
+	n       int // Bytes of pixels used by the committed frame
+	pixels  [MaxDisplayWidth * MaxDisplayHeight / 8]byte
+}
+
+// SetDoubleBuffered enables or disables double buffering of the display. While
+// double buffering is enabled, instructions draw to a working display, which is
+// committed at the end of every frame by [Emulator.Clock], and
+// [Emulator.DisplayInto] returns the committed display. Enabling double
+// buffering commits the current display, and so do [Emulator.Reset],
+// [Emulator.SetResolution], and restoring a saved state, which replace the
+// display outside of any frame.
+//
+// Double buffering allows the display to be rendered while the emulator runs on
+// a different goroutine: DisplayInto is then safe to call concurrently with
+// [Emulator.Step] and Clock, and never returns a partially drawn frame. The
+// other methods of the emulator are not safe for concurrent use. Double
+// buffering is disabled by default.
+func (e *Emulator) SetDoubleBuffered(enabled bool) {
+	e.frame.mu.Lock()
+	defer e.frame.mu.Unlock()
+
+	e.frame.enabled = enabled
+
+	if enabled {
+		e.frame.n = e.packDisplay(e.frame.pixels[:])
+	}
+}
+
+// commitFrame copies the working display to the committed display, if double
+// buffering is enabled.
+func (e *Emulator) commitFrame() {
+	e.frame.mu.Lock()
+	defer e.frame.mu.Unlock()
+
+	if e.frame.enabled {
+		e.frame.n = e.packDisplay(e.frame.pixels[:])
+	}
+}
+
+// committedDisplayInto copies the committed display into dst. It returns false
+// if double buffering is disabled.
+func (e *Emulator) committedDisplayInto(dst []byte) (int, bool, error) {
+	e.frame.mu.Lock()
+	defer e.frame.mu.Unlock()
+
+	if !e.frame.enabled {
+		return 0, false, nil
+	}
+
+	if len(dst) < e.frame.n {
+		return 0, true, fmt.Errorf("buffer too small: %d bytes, need %d", len(dst), e.frame.n)
+	}
+
+	return copy(dst, e.frame.pixels[:e.frame.n]), true, nil
+}
@@ -0,0 +1,209 @@
+package emulator_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestDoubleBuffered(t *testing.T) {
+	e := emulator.New()
+
+	// Every frame clears the display and draws the sprite of the digit 0 at
+	// (0, 0). Without double buffering, the display is blank between CLS and DRW.
+
+	program := []uint8{
+		0x00, 0xe0, // CLS
+		0xd0, 0x05, // DRW V0, V0, 0x05
+		0x12, 0x00, // JP 0x200
+	}
+
+	if err := e.Load(program); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	frame := func() {
+		for range 3 {
+			if _, err := e.Step(); err != nil {
+				t.Errorf("step: %v", err)
+			}
+		}
+		e.Clock()
+	}
+
+	frame()
+
+	e.SetDoubleBuffered(true)
+
+	want := make([]byte, emulator.DisplayWidth*emulator.DisplayHeight/8)
+
+	for i, row := range []byte{0xf0, 0x90, 0x90, 0x90, 0xf0} {
+		want[i*8] = row
+	}
+
+	// Stop in the middle of the next frame, after the display has been cleared.
+
+	if _, err := e.Step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	buf := make([]byte, len(want))
+
+	if _, err := e.DisplayInto(buf); err != nil {
+		t.Fatalf("display: %v", err)
+	}
+
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("packed display:\ngot  %x\nwant %x", buf, want)
+	}
+
+	if _, err := e.DisplayInto(buf[:len(buf)-1]); err == nil {
+		t.Fatal("expected error for short buffer")
+	}
+
+	e.SetDoubleBuffered(false)
+
+	if _, err := e.DisplayInto(buf); err != nil {
+		t.Fatalf("display: %v", err)
+	}
+
+	if !bytes.Equal(buf, make([]byte, len(buf))) {
+		t.Fatalf("packed display: got %x, want a blank display", buf)
+	}
+}
+
+func TestDoubleBufferedConcurrent(t *testing.T) {
+	e := emulator.New()
+
+	program := []uint8{
+		0x00, 0xe0, // CLS
+		0xd0, 0x05, // DRW V0, V0, 0x05
+		0x12, 0x00, // JP 0x200
+	}
+
+	if err := e.Load(program); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// The first frame runs without double buffering, so that the committed
+	// display contains the sprite from the start.
+
+	for range 2 {
+		if _, err := e.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+	}
+
+	e.SetDoubleBuffered(true)
+
+	want := make([]byte, emulator.DisplayWidth*emulator.DisplayHeight/8)
+
+	if _, err := e.DisplayInto(want); err != nil {
+		t.Fatalf("display: %v", err)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for range 1000 {
+			for range 3 {
+				if _, err := e.Step(); err != nil {
+					t.Errorf("step: %v", err)
+					return
+				}
+			}
+			e.Clock()
+		}
+	}()
+
+	buf := make([]byte, len(want))
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		if _, err := e.DisplayInto(buf); err != nil {
+			t.Fatalf("display: %v", err)
+		}
+
+		if !bytes.Equal(buf, want) {
+			t.Fatalf("torn frame:\ngot  %x\nwant %x", buf, want)
+		}
+	}
+}
+
+func TestDoubleBufferedOutsideFrames(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0xd0, 0x05, // DRW V0, V0, 0x05
+		0x00, 0x00, // HALT
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	e.SetDoubleBuffered(true)
+
+	saved, err := e.MarshalBinary()
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	// Every step commits a frame with the sprite, which the following change to
+	// the display must replace without waiting for the end of a frame.
+
+	draw := func() {
+		t.Helper()
+
+		if _, err := e.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+
+		e.Clock()
+	}
+
+	blank := func(name string, want int) {
+		t.Helper()
+
+		buf := make([]byte, emulator.MaxDisplayWidth*emulator.MaxDisplayHeight/8)
+
+		n, err := e.DisplayInto(buf)
+		if err != nil {
+			t.Fatalf("%s: display: %v", name, err)
+		}
+
+		if n != want {
+			t.Fatalf("%s: got %d bytes, want %d", name, n, want)
+		}
+
+		if !bytes.Equal(buf[:n], make([]byte, n)) {
+			t.Fatalf("%s: got %x, want a blank display", name, buf[:n])
+		}
+	}
+
+	draw()
+	e.Reset()
+	blank("reset", emulator.DisplayWidth*emulator.DisplayHeight/8)
+
+	draw()
+
+	if err := e.UnmarshalBinary(saved); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	blank("restore", emulator.DisplayWidth*emulator.DisplayHeight/8)
+
+	draw()
+
+	if err := e.SetResolution(emulator.MaxDisplayWidth, emulator.MaxDisplayHeight); err != nil {
+		t.Fatalf("resolution: %v", err)
+	}
+
+	blank("resolution", emulator.MaxDisplayWidth*emulator.MaxDisplayHeight/8)
+}
@@ -0,0 +1,66 @@
+package emulator
+
+import "fmt"
+
+// Fault is the error returned by [Emulator.Step] in chaos mode. It wraps the
+// error of the instruction, or the panic it caused.
+type Fault struct {
+	PC  uint16 // Address of the instruction that failed
+	Err error  // Why the instruction failed
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("fault at %04x: %v", f.PC, f.Err)
+}
+
+func (f *Fault) Unwrap() error {
+	return f.Err
+}
+
+// SetChaos enables the chaos mode, a robustness testing aid that simulates
+// corrupted memory. Before every instruction, with a probability of 1 in rate,
+// a random bit of memory is flipped. The flip is reported to the memory tracer
+// like any other write. The random numbers are taken from rng, so that a run
+// can be reproduced from its seed.
+//
+// In chaos mode, [Emulator.Step] never panics: every failure, including the
+// ones that would otherwise panic, is returned as a *[Fault]. The state of the
+// emulator after a fault is unspecified, and it should be reset with
+// [Emulator.Reset].
+//
+// Pass a rate of 0, the default, to disable the chaos mode.
+func (e *Emulator) SetChaos(rate uint32, rng func() uint32) {
+	if rng == nil {
+		rate = 0
+	}
+	e.chaosRate = rate
+	e.chaosRNG = rng
+}
+
+func (e *Emulator) chaosStep() (ok bool, err error) {
+	pc := e.state.PC
+
+	defer func() {
+		if r := recover(); r != nil {
+			ok, err = false, &Fault{PC: pc, Err: fmt.Errorf("panic: %v", r)}
+		}
+	}()
+
+	if e.chaosRNG()%e.chaosRate == 0 {
+		e.flipBit()
+	}
+
+	ok, err = e.step()
+	if err != nil {
+		return ok, &Fault{PC: pc, Err: err}
+	}
+
+	return ok, nil
+}
+
+// flipBit flips a random bit of memory.
+func (e *Emulator) flipBit() {
+	r := e.chaosRNG()
+	addr := uint16((r >> 3) % uint32(len(e.state.Memory)))
+	e.writeMemory(addr, e.state.Memory[addr]^(1<<(r&7)))
+}
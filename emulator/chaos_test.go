@@ -0,0 +1,88 @@
+package emulator_test
+
+import (
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// runChaos runs the rom in chaos mode for the given number of steps, resetting
+// the emulator after every fault. It returns the faults and the final hash.
+func runChaos(t *testing.T, rom []byte, seed uint64, steps int) (int, uint64) {
+	t.Helper()
+
+	e := emulator.New()
+
+	if err := e.Load(rom); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	e.SetRNG(rng.Uint32)
+	e.SetChaos(2, rng.Uint32)
+
+	var faults int
+
+	for i := range steps {
+		ok, err := e.Step()
+
+		if err != nil {
+			var fault *emulator.Fault
+			if !errors.As(err, &fault) {
+				t.Fatalf("step %d: untyped error: %v", i, err)
+			}
+			faults++
+		}
+
+		if err != nil || !ok {
+			e.Reset()
+		}
+
+		if i%emulator.DefaultStepsPerFrame == 0 {
+			e.Clock()
+		}
+	}
+
+	return faults, e.StateHash()
+}
+
+func TestChaos(t *testing.T) {
+	rom, err := os.ReadFile(filepath.Join("..", "roms", "3-corax+.ch8"))
+	if err != nil {
+		t.Fatalf("read rom: %v", err)
+	}
+
+	// A panic escaping Step fails the test, while faults are expected: with so
+	// many bits flipped, the program eventually runs into invalid instructions
+	// and memory accesses out of bounds.
+
+	var faults int
+
+	for seed := range uint64(20) {
+		n, _ := runChaos(t, rom, seed, 20000)
+		faults += n
+	}
+
+	if faults == 0 {
+		t.Fatalf("no faults")
+	}
+}
+
+func TestChaosDeterministic(t *testing.T) {
+	rom, err := os.ReadFile(filepath.Join("..", "roms", "3-corax+.ch8"))
+	if err != nil {
+		t.Fatalf("read rom: %v", err)
+	}
+
+	faults1, hash1 := runChaos(t, rom, 42, 5000)
+	faults2, hash2 := runChaos(t, rom, 42, 5000)
+
+	if faults1 != faults2 || hash1 != hash2 {
+		t.Fatalf("runs differ: %d faults, hash %x and %d faults, hash %x", faults1, hash1, faults2, hash2)
+	}
+}
@@ -1,8 +1,13 @@
 package emulator
 
 import (
+	"encoding/binary"
 	"fmt"
+	"math"
+	"math/bits"
 	"math/rand/v2"
+	"slices"
+	"time"
 )
 
 var fonts = [16 * FontSize]uint8{
@@ -24,17 +29,70 @@ var fonts = [16 * FontSize]uint8{
 	0xf0, 0x80, 0xf0, 0x80, 0x80, // F
 }
 
+// largeFonts holds the 8×10 digits of SUPER-CHIP. SUPER-CHIP only defines the
+// digits from 0 to 9, and the letters are the ones used by Octo.
+var largeFonts = [16 * LargeFontSize]uint8{
+	0xff, 0xff, 0xc3, 0xc3, 0xc3, 0xc3, 0xc3, 0xc3, 0xff, 0xff, // 0
+	0x18, 0x78, 0x78, 0x18, 0x18, 0x18, 0x18, 0x18, 0xff, 0xff, // 1
+	0xff, 0xff, 0x03, 0x03, 0xff, 0xff, 0xc0, 0xc0, 0xff, 0xff, // 2
+	0xff, 0xff, 0x03, 0x03, 0xff, 0xff, 0x03, 0x03, 0xff, 0xff, // 3
+	0xc3, 0xc3, 0xc3, 0xc3, 0xff, 0xff, 0x03, 0x03, 0x03, 0x03, // 4
+	0xff, 0xff, 0xc0, 0xc0, 0xff, 0xff, 0x03, 0x03, 0xff, 0xff, // 5
+	0xff, 0xff, 0xc0, 0xc0, 0xff, 0xff, 0xc3, 0xc3, 0xff, 0xff, // 6
+	0xff, 0xff, 0x03, 0x03, 0x06, 0x0c, 0x18, 0x18, 0x18, 0x18, // 7
+	0xff, 0xff, 0xc3, 0xc3, 0xff, 0xff, 0xc3, 0xc3, 0xff, 0xff, // 8
+	0xff, 0xff, 0xc3, 0xc3, 0xff, 0xff, 0x03, 0x03, 0xff, 0xff, // 9
+	0x7e, 0xff, 0xc3, 0xc3, 0xc3, 0xff, 0xff, 0xc3, 0xc3, 0xc3, // A
+	0xfc, 0xfc, 0xc3, 0xc3, 0xfc, 0xfc, 0xc3, 0xc3, 0xfc, 0xfc, // B
+	0x3c, 0xff, 0xc3, 0xc0, 0xc0, 0xc0, 0xc0, 0xc3, 0xff, 0x3c, // C
+	0xfc, 0xfe, 0xc3, 0xc3, 0xc3, 0xc3, 0xc3, 0xc3, 0xfe, 0xfc, // D
+	0xff, 0xff, 0xc0, 0xc0, 0xff, 0xff, 0xc0, 0xc0, 0xff, 0xff, // E
+	0xff, 0xff, 0xc0, 0xc0, 0xff, 0xff, 0xc0, 0xc0, 0xc0, 0xc0, // F
+}
+
+// spreadRows maps every row of a sprite to the eight pixels it covers, packed in
+// a little-endian 64-bit word where every byte is a pixel. The leftmost pixel of
+// the row is the least significant byte.
+var spreadRows = func() (rows [256]uint64) {
+	for row := range rows {
+		for dx := range SpriteWidth {
+			if row&(0x80>>dx) != 0 {
+				rows[row] |= 1 << (8 * dx)
+			}
+		}
+	}
+	return rows
+}()
+
 // ProgramStart is the address in memory where programs are loaded and executed.
 const ProgramStart = 0x200
 
 // FontSize is the number of bytes in each font sprite.
 const FontSize = 5
 
+// LargeFontSize is the number of bytes in each large font sprite.
+const LargeFontSize = 10
+
+// LargeFontStart is the address in memory of the large font, right after the
+// small one.
+const LargeFontStart = len(fonts)
+
+// AllPlanes selects both bitplanes of the display. See [Display] for the
+// meaning of the bits.
+const AllPlanes = 0x3
+
+// DefaultPitch is the pitch of the audio pattern after a reset, which plays the
+// pattern at 4000 samples per second. Every 48 steps of pitch double the rate.
+const DefaultPitch = 64
+
 // Display and sprite geometry.
 const (
-	DisplayWidth  = 64 // Width of the display in pixels.
-	DisplayHeight = 32 // Height of the display in pixels.
-	SpriteWidth   = 8  // Width of a sprite in pixels.
+	DisplayWidth     = 64  // Default width of the display in pixels.
+	DisplayHeight    = 32  // Default height of the display in pixels.
+	MaxDisplayWidth  = 128 // Maximum width of the display in pixels.
+	MaxDisplayHeight = 64  // Maximum height of the display in pixels.
+	SpriteWidth      = 8   // Width of a sprite in pixels.
+	LargeSpriteSize  = 16  // Width and height of a large sprite in pixels.
 )
 
 // Masks for extracting parts of an opcode.
@@ -78,6 +136,13 @@ const (
 	OpHALT = 0x0000 // Halt the emulator. Non-standard extension.
 	OpCLS  = 0x00e0 // CLS: clear the display.
 	OpRET  = 0x00ee // RET: return from a subroutine.
+	OpSCD  = 0x00c0 // SCD nibble: scroll the display down N rows, matched with N cleared. SUPER-CHIP extension.
+	OpSCU  = 0x00d0 // SCU nibble: scroll the display up N rows, matched with N cleared. XO-CHIP extension.
+	OpSCR  = 0x00fb // SCR: scroll the display right 4 columns. SUPER-CHIP extension.
+	OpSCL  = 0x00fc // SCL: scroll the display left 4 columns. SUPER-CHIP extension.
+	OpEXIT = 0x00fd // EXIT: stop the program. SUPER-CHIP extension.
+	OpLOW  = 0x00fe // LOW: switch to the low resolution. SUPER-CHIP extension.
+	OpHIGH = 0x00ff // HIGH: switch to the high resolution. SUPER-CHIP extension.
 )
 
 // Sub-opcodes for [OpTypeALU], matched against op & [MaskN].
@@ -101,42 +166,245 @@ const (
 
 // Sub-opcodes for [OpTypeMisc], matched against op & [MaskKK].
 const (
+	OpLDIL  = 0x0000 // LD I, long addr: load the 16-bit address in the next two bytes into I. XO-CHIP extension.
+	OpPLANE = 0x0001 // PLANE n: select the bitplanes drawn by DRW, where n is the X nibble. XO-CHIP extension.
+	OpAUDIO = 0x0002 // AUDIO: load the 16 bytes at I into the audio pattern. XO-CHIP extension.
 	OpLDVDT = 0x0007 // LD Vx, DT: load the delay timer value into Vx.
 	OpLDVK  = 0x000a // LD Vx, K: wait for a key press and load the key into Vx.
 	OpLDDTV = 0x0015 // LD DT, Vx: load Vx into the delay timer.
 	OpLDSTV = 0x0018 // LD ST, Vx: load Vx into the sound timer.
 	OpADDIV = 0x001e // ADD I, Vx: set I = I + Vx.
 	OpLDF   = 0x0029 // LD F, Vx: load the address of the sprite for digit Vx into I.
+	OpLDHF  = 0x0030 // LD HF, Vx: load the address of the large sprite for digit Vx into I. SUPER-CHIP extension.
 	OpLDB   = 0x0033 // LD B, Vx: store the BCD representation of Vx at I, I+1, I+2.
+	OpPITCH = 0x003a // PITCH Vx: set the pitch of the audio pattern to Vx. XO-CHIP extension.
 	OpSTMV  = 0x0055 // LD [I], Vx: store registers V0 through Vx in memory starting at I.
 	OpLDVM  = 0x0065 // LD Vx, [I]: load registers V0 through Vx from memory starting at I.
+	OpLDRV  = 0x0075 // LD R, Vx: store registers V0 through Vx in the RPL flags. SUPER-CHIP extension.
+	OpLDVR  = 0x0085 // LD Vx, R: load registers V0 through Vx from the RPL flags. SUPER-CHIP extension.
 )
 
 type (
-	// Memory is the 4096-byte addressable memory of the CHIP-8.
-	Memory [4096]uint8
+	// Memory is the addressable memory of the CHIP-8. CHIP-8 programs only use
+	// the first 4096 bytes, while XO-CHIP extends the memory to 64KB.
+	Memory [65536]uint8
 	// Registers holds the 16 general-purpose 8-bit registers V0 through VF.
 	Registers [16]uint8
+	// RPLFlags holds the 8 user flags of SUPER-CHIP, named after the RPL
+	// language of the HP-48 calculators, which stored them.
+	RPLFlags [8]uint8
+	// AudioPattern holds the 128 1-bit samples of the audio of XO-CHIP, played
+	// from the most significant bit of the first byte.
+	AudioPattern [16]uint8
 	// Stack holds the up to 16 return addresses pushed by CALL instructions.
 	Stack [16]uint16
-	// Display is the 64×32 monochrome pixel framebuffer.
-	Display [DisplayHeight][DisplayWidth]uint8
+	// Display is the pixel framebuffer. It is large enough for the maximum
+	// resolution, but only the top-left area covered by the active resolution
+	// is used. Every pixel holds the bitplanes it is on in: bit 0 for the first
+	// plane, and bit 1 for the second one, which only XO-CHIP uses. A pixel is
+	// on if it is on in any plane.
+	Display [MaxDisplayHeight][MaxDisplayWidth]uint8
 	// Keys holds the pressed state of the 16 keys of the hexadecimal keypad.
 	Keys [16]bool
 )
 
+// TraceSize is the number of instructions kept by the trace returned by
+// [Emulator.RecentInstructions].
+const TraceSize = 64
+
+// TracePoint records an instruction executed by the emulator.
+type TracePoint struct {
+	PC uint16 // Address of the instruction
+	Op uint16 // Opcode of the instruction
+}
+
+// traceRing is a circular buffer of the most recently executed instructions.
+type traceRing struct {
+	points    [TraceSize]TracePoint
+	snapshots *[TraceSize]snapshot // State before every instruction, if rewind is enabled
+	next      int                  // Where the next instruction will be recorded
+	size      int                  // Number of recorded instructions, up to TraceSize
+}
+
+// snapshot is the state before an instruction, saved for [Emulator.Rewind].
+// Copying the whole memory before every instruction would be too slow, so the
+// snapshot keeps the bytes overwritten after it was taken instead, and the
+// memory is restored by undoing the writes of the newer snapshots too.
+type snapshot struct {
+	cpu     cpuState
+	display Display
+	writes  []memoryWrite // Writes since the snapshot, oldest first
+}
+
+// memoryWrite is a byte of memory overwritten after a snapshot.
+type memoryWrite struct {
+	addr uint16
+	old  uint8 // Value before the write
+}
+
+// record records the instruction op at pc, which is about to be executed in
+// state.
+func (r *traceRing) record(pc, op uint16, state *State) {
+	r.points[r.next] = TracePoint{PC: pc, Op: op}
+	if r.snapshots != nil {
+		s := &r.snapshots[r.next]
+		s.cpu.save(state)
+		s.display = state.Display
+		s.writes = s.writes[:0]
+	}
+	r.next = (r.next + 1) % TraceSize
+	r.size = min(r.size+1, TraceSize)
+}
+
+// recordWrite records that the byte at addr, whose value was old, is being
+// overwritten, so that rewinding to the newest snapshot or before it restores
+// the byte.
+func (r *traceRing) recordWrite(addr uint16, old uint8) {
+	if r.snapshots == nil || r.size == 0 {
+		return
+	}
+	s := &r.snapshots[r.index(r.size-1)]
+	s.writes = append(s.writes, memoryWrite{addr: addr, old: old})
+}
+
+// index returns the position in the buffer of the i-th recorded instruction,
+// from the oldest to the newest.
+func (r *traceRing) index(i int) int {
+	return (r.next - r.size + i + TraceSize) % TraceSize
+}
+
+// FlagCause describes why an instruction has written VF.
+type FlagCause int
+
+// Causes of a write to VF.
+const (
+	FlagLogic     FlagCause = iota // OR, AND, or XOR reset VF.
+	FlagCarry                      // ADD stored the carry.
+	FlagBorrow                     // SUB or SUBN stored the inverted borrow.
+	FlagShift                      // SHR or SHL stored the shifted-out bit.
+	FlagCollision                  // DRW stored the collision flag.
+)
+
+func (c FlagCause) String() string {
+	switch c {
+	case FlagLogic:
+		return "logic"
+	case FlagCarry:
+		return "carry"
+	case FlagBorrow:
+		return "borrow"
+	case FlagShift:
+		return "shift"
+	case FlagCollision:
+		return "collision"
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(c))
+}
+
+// FlagTracer is called when the instruction op writes value to VF because of
+// cause.
+type FlagTracer func(op uint16, value uint8, cause FlagCause)
+
+// RegisterStat counts the accesses to a general-purpose register.
+type RegisterStat struct {
+	Reads  uint64 // Number of times the register was read
+	Writes uint64 // Number of times the register was written
+}
+
+// MemTracer is called when an instruction reads value from, or writes value to,
+// the memory at addr.
+type MemTracer func(addr uint16, write bool, value uint8)
+
 // State is a snapshot of the complete CHIP-8 machine state.
 type State struct {
-	V       Registers // General-purpose registers
-	I       uint16    // Index register
-	SP      uint8     // Index to the next available stack entry
-	DT      uint8     // Delay timer
-	ST      uint8     // Sound timer
-	PC      uint16    // Program counter
-	Stack   Stack     // The stack
-	Memory  Memory    // The memory
-	Display Display   // The display
-	Keys    Keys      // Currently pressed keys
+	V       Registers    // General-purpose registers
+	I       uint16       // Index register
+	SP      uint8        // Index to the next available stack entry
+	DT      uint8        // Delay timer
+	ST      uint8        // Sound timer
+	PC      uint16       // Program counter
+	Stack   Stack        // The stack
+	Flags   RPLFlags     // User flags of SUPER-CHIP, saved by LD R, Vx
+	Memory  Memory       // The memory
+	Display Display      // The display
+	Width   int          // Width of the active display area
+	Height  int          // Height of the active display area
+	Keys    Keys         // Currently pressed keys
+	Planes  uint8        // Bitplanes drawn by DRW, selected by PLANE
+	Pattern AudioPattern // Audio pattern, loaded by AUDIO
+	Pitch   uint8        // Pitch of the audio pattern, set by PITCH
+
+	PatternLoaded  bool   // Has AUDIO been executed since the last reset?
+	DrawsThisFrame int    // Sprites drawn since the last call to Clock
+	FrameCount     uint64 // Frames started by calls to Clock
+}
+
+// cpuState holds the fields of [State] other than the memory and the display,
+// which are too large to be copied after every instruction.
+type cpuState struct {
+	V              Registers
+	I              uint16
+	SP             uint8
+	DT             uint8
+	ST             uint8
+	PC             uint16
+	Stack          Stack
+	Flags          RPLFlags
+	Width          int
+	Height         int
+	Keys           Keys
+	Planes         uint8
+	Pattern        AudioPattern
+	Pitch          uint8
+	PatternLoaded  bool
+	DrawsThisFrame int
+	FrameCount     uint64
+}
+
+// save copies the fields of s, except for the memory and the display, into c.
+func (c *cpuState) save(s *State) {
+	*c = cpuState{
+		V:              s.V,
+		I:              s.I,
+		SP:             s.SP,
+		DT:             s.DT,
+		ST:             s.ST,
+		PC:             s.PC,
+		Stack:          s.Stack,
+		Flags:          s.Flags,
+		Width:          s.Width,
+		Height:         s.Height,
+		Keys:           s.Keys,
+		Planes:         s.Planes,
+		Pattern:        s.Pattern,
+		Pitch:          s.Pitch,
+		PatternLoaded:  s.PatternLoaded,
+		DrawsThisFrame: s.DrawsThisFrame,
+		FrameCount:     s.FrameCount,
+	}
+}
+
+// restore copies c into the fields of s, leaving the memory and the display of
+// s unchanged.
+func (c *cpuState) restore(s *State) {
+	s.V = c.V
+	s.I = c.I
+	s.SP = c.SP
+	s.DT = c.DT
+	s.ST = c.ST
+	s.PC = c.PC
+	s.Stack = c.Stack
+	s.Flags = c.Flags
+	s.Width = c.Width
+	s.Height = c.Height
+	s.Keys = c.Keys
+	s.Planes = c.Planes
+	s.Pattern = c.Pattern
+	s.Pitch = c.Pitch
+	s.PatternLoaded = c.PatternLoaded
+	s.DrawsThisFrame = c.DrawsThisFrame
+	s.FrameCount = c.FrameCount
 }
 
 // Instruction returns the 16-bit opcode at the current program counter.
@@ -151,19 +419,109 @@ type Emulator struct {
 	waitKeyRegister uint8         // Where to store the pressed key, if waiting
 	rng             func() uint32 // Random number generator
 	sound           func()        // Callback called when the sound timer expires
+	quirks          Quirks        // Behaviors that differ across interpreters
+	drawn           bool          // Was a sprite ever drawn with the display wait quirk?
+	drawFrame       uint64        // Frame of the last sprite drawn with the display wait quirk
+	program         []uint8       // Program loaded by Load, used by Reset
+	lastDraw        drawRecord    // Most recent DRW, used by UndoLastDraw
+	flagTracer      FlagTracer    // Callback called when an instruction sets VF
+	timersPaused    bool          // Ignore timer ticks?
+	cpuPaused       bool          // Ignore steps?
+	trace           traceRing     // Most recently executed instructions
+	memTracer       MemTracer     // Callback called when an instruction accesses memory
+	registerStats   [16]RegisterStat
+	collisions      uint64          // Sprites drawn over pixels that were already on
+	keyEvents       []KeyEvent      // Key events queued by QueueKey
+	stepsPerFrame   int             // Instructions executed by StepFrameWithInput
+	checkAlignment  bool            // Fail jumps to odd addresses?
+	writeAheadTrap  int             // Bytes after the instruction that can't be written
+	halted          bool            // Has a HALT instruction been executed?
+	haltPC          uint16          // Address of the HALT instruction, if halted
+	exited          bool            // Was the program halted by EXIT, rather than HALT?
+	tickBacklog     time.Duration   // Time passed to Tick, not yet spent on a frame
+	frame           frameBuffer     // Display committed at the end of the last frame
+	maxDraws        int             // Sprites drawn in every frame, or 0 for no limit
+	drawsSkipped    int             // Sprites not drawn in this frame because of maxDraws
+	drawLimit       func(uint64)    // Callback called when a frame exceeds maxDraws
+	chaosRate       uint32          // Instructions per bit flip, on average, or 0 if disabled
+	chaosRNG        func() uint32   // Random number generator of the chaos mode
+	called          map[uint16]bool // Addresses called by CALL since the last reset
+	executed        [8192]uint8     // Bytes of memory executed since the last reset, one bit each
+	handlers        []opcodeHandler // Handlers installed by SetOpcodeHandler, in installation order
+}
+
+// KeyEvent is a key of the keypad being pressed or released.
+type KeyEvent struct {
+	Down bool  // Was the key pressed, rather than released?
+	Key  uint8 // The key, from 0x0 to 0xF
+}
+
+// drawRecord captures what is needed to revert the effect of a DRW instruction.
+type drawRecord struct {
+	x, y   int                        // Coordinates of the sprite, already wrapped
+	sprite [4 * LargeSpriteSize]uint8 // Rows of the sprite for every plane, as read from memory
+	height uint8                      // Number of rows of the sprite
+	large  bool                       // Is the sprite 16×16, with two bytes per row?
+	planes uint8                      // Bitplanes the sprite was drawn in
+	vf     uint8                      // Value of VF before the instruction
+	valid  bool                       // Is there a draw to revert?
 }
 
 // New returns a new Emulator ready to execute a program loaded with [Emulator.Load].
 func New() *Emulator {
 	var e Emulator
 
+	e.quirks = DefaultQuirks()
+	e.stepsPerFrame = DefaultStepsPerFrame
+	e.state.Width = DisplayWidth
+	e.state.Height = DisplayHeight
+	e.initialize()
+
+	return &e
+}
+
+// initialize brings the machine state and the execution state of the emulator
+// to their power-on values.
+func (e *Emulator) initialize() {
+	width, height := e.state.Width, e.state.Height
+
+	e.state = State{}
+	e.state.Width = width
+	e.state.Height = height
+	e.state.Planes = 1
+	e.state.Pitch = DefaultPitch
+	e.waitKey = false
+	e.waitKeyRegister = 0
+	e.drawn = false
+	e.drawFrame = 0
+	e.lastDraw = drawRecord{}
+	e.trace = traceRing{snapshots: e.trace.snapshots}
+	e.registerStats = [16]RegisterStat{}
+	e.collisions = 0
+	e.called = nil
+	e.executed = [len(e.executed)]uint8{}
+	e.keyEvents = nil
+	e.halted = false
+	e.haltPC = 0
+	e.exited = false
+	e.tickBacklog = 0
+	e.drawsSkipped = 0
+
 	// Copy the fonts to the beginning of the memory.
 	copy(e.state.Memory[:], fonts[:])
+	copy(e.state.Memory[LargeFontStart:], largeFonts[:])
 
 	// Set the program counter to the beginning of the program's memory.
 	e.state.PC = ProgramStart
+}
 
-	return &e
+// Reset brings the emulator back to the state it had right after the program
+// was loaded with [Emulator.Load]. The configuration of the emulator, like the
+// quirks, the resolution, and the callbacks, is preserved.
+func (e *Emulator) Reset() {
+	e.initialize()
+	copy(e.state.Memory[ProgramStart:], e.program)
+	e.commitFrame()
 }
 
 // State copies the current machine state into the provided [State].
@@ -171,9 +529,32 @@ func (e *Emulator) State(state *State) {
 	*state = e.state
 }
 
+// CPUState copies the current machine state into the provided [State], except
+// for the memory and the display, which are left unchanged. It is much cheaper
+// than [Emulator.State], which copies the 64KB of memory, so it suits the checks
+// made after every instruction, like breakpoints.
+func (e *Emulator) CPUState(state *State) {
+	var c cpuState
+	c.save(&e.state)
+	c.restore(state)
+}
+
 // Clock advances the delay and sound timers by one tick. When the sound timer
 // reaches zero, the sound callback registered with [Emulator.SetSound] is called.
+// Every tick also marks the beginning of a new frame, even if the timers are
+// disabled with [Emulator.SetTimersEnabled], and increments
+// [State.FrameCount].
 func (e *Emulator) Clock() {
+	e.commitFrame()
+
+	e.state.FrameCount++
+	e.state.DrawsThisFrame = 0
+	e.drawsSkipped = 0
+
+	if e.timersPaused {
+		return
+	}
+
 	if e.state.DT > 0 {
 		e.state.DT--
 	}
@@ -198,23 +579,437 @@ func (e *Emulator) KeyUp(key uint8) {
 	e.state.Keys[key&0xf] = false
 
 	if e.waitKey {
-		e.state.V[e.waitKeyRegister] = key
+		e.writeRegister(uint16(e.waitKeyRegister), key)
 		e.waitKey = false
 		e.state.PC += 2
 	}
 }
 
+// QueueKey queues the press or the release of a key. Unlike [Emulator.KeyDown]
+// and [Emulator.KeyUp], the event doesn't take effect immediately. Queued events
+// are applied in order, one at the start of every call to [Emulator.Step], so
+// that the program observes both the press and the release of a key tapped
+// within a single frame.
+func (e *Emulator) QueueKey(down bool, key uint8) {
+	e.keyEvents = append(e.keyEvents, KeyEvent{Down: down, Key: key})
+}
+
+// AnyKeyPressed returns true if at least one key of the keypad is pressed. A
+// front-end skipping the steps of an idle program can use it to detect when the
+// program should be resumed.
+func (e *Emulator) AnyKeyPressed() bool {
+	for _, pressed := range e.state.Keys {
+		if pressed {
+			return true
+		}
+	}
+	return false
+}
+
+// UndoLastDraw reverts the effect of the most recent DRW instruction on the
+// display and on VF. Sprites are drawn with XOR, so drawing the same sprite at
+// the same position again erases it. It returns false if there is no draw to
+// revert, because no sprite has been drawn since the display was last cleared
+// or because the last draw was already reverted.
+func (e *Emulator) UndoLastDraw() bool {
+	d := &e.lastDraw
+
+	if !d.valid {
+		return false
+	}
+
+	e.xorPlanes(d.x, d.y, d.sprite[:], int(d.height), d.large, d.planes)
+
+	e.state.V[0xf] = d.vf
+	d.valid = false
+
+	return true
+}
+
+// RecentInstructions returns up to [TraceSize] of the most recently executed
+// instructions, from the oldest to the newest. The newest instruction is the
+// last one passed to [Emulator.Step], even if it failed.
+func (e *Emulator) RecentInstructions() []TracePoint {
+	r := &e.trace
+
+	points := make([]TracePoint, r.size)
+
+	for i := range points {
+		points[i] = r.points[r.index(i)]
+	}
+
+	return points
+}
+
+// StackTop returns the most recent address pushed on the stack by CALL, which
+// is the address of the CALL instruction itself: RET returns to the instruction
+// following it. It returns false if the stack is empty.
+func (e *Emulator) StackTop() (uint16, bool) {
+	stack := e.StackSlice()
+
+	if len(stack) == 0 {
+		return 0, false
+	}
+
+	return stack[len(stack)-1], true
+}
+
+// StackSlice returns a copy of the addresses on the stack, from the oldest to
+// the most recent. Its length is SP.
+func (e *Emulator) StackSlice() []uint16 {
+	n := min(int(e.state.SP), len(e.state.Stack))
+
+	stack := make([]uint16, n)
+	copy(stack, e.state.Stack[:n])
+
+	return stack
+}
+
+// SpriteAt returns a copy of the height bytes of memory starting at I, which
+// are the rows DRW would draw with that height. Reading them is not reported to
+// the memory tracer. It returns an error if height is negative, or if the
+// sprite extends past the end of the memory.
+func (e *Emulator) SpriteAt(height int) ([]byte, error) {
+	if height < 0 {
+		return nil, fmt.Errorf("invalid sprite height: %d", height)
+	}
+
+	start := int(e.state.I)
+
+	if start+height > len(e.state.Memory) {
+		return nil, fmt.Errorf("sprite at %03x with height %d exceeds memory", start, height)
+	}
+
+	sprite := make([]byte, height)
+	copy(sprite, e.state.Memory[start:start+height])
+
+	return sprite, nil
+}
+
+// SetRewindEnabled enables or disables [Emulator.Rewind]. When enabled, the
+// emulator saves the state before every instruction in the trace returned by
+// [Emulator.RecentInstructions], which makes every step slower. The memory is
+// not copied: the bytes overwritten by every instruction are saved instead.
+// Rewind is disabled by default.
+func (e *Emulator) SetRewindEnabled(enabled bool) {
+	if !enabled {
+		e.trace.snapshots = nil
+	} else if e.trace.snapshots == nil {
+		e.trace.snapshots = new([TraceSize]snapshot)
+	}
+}
+
+// Rewind restores the state before the execution of the instruction at index i
+// of the slice returned by [Emulator.RecentInstructions]. The instruction and
+// the following ones are removed from the trace, and they are recorded again
+// if they are executed. Rewinding also forgets the draw reverted by
+// [Emulator.UndoLastDraw] and any wait for a key press. It returns an error if
+// rewind is not enabled, or if i is out of range. Instructions executed before
+// rewind was enabled can't be restored.
+func (e *Emulator) Rewind(i int) error {
+	r := &e.trace
+
+	if r.snapshots == nil {
+		return fmt.Errorf("rewind not enabled")
+	}
+
+	if i < 0 || i >= r.size {
+		return fmt.Errorf("invalid instruction %d (trace size %d)", i, r.size)
+	}
+
+	j := r.index(i)
+
+	if r.snapshots[j].cpu.Width == 0 {
+		return fmt.Errorf("instruction %d executed before rewind was enabled", i)
+	}
+
+	// Undo the writes from the newest to the oldest, so that a byte written more
+	// than once gets the value it had before the first write.
+
+	for k := r.size - 1; k >= i; k-- {
+		writes := r.snapshots[r.index(k)].writes
+
+		for w := len(writes) - 1; w >= 0; w-- {
+			e.state.Memory[writes[w].addr] = writes[w].old
+		}
+	}
+
+	r.snapshots[j].cpu.restore(&e.state)
+	e.state.Display = r.snapshots[j].display
+	e.waitKey = false
+	e.lastDraw = drawRecord{}
+
+	r.next = j
+	r.size = i
+
+	return nil
+}
+
+// Halted returns true if the program executed a HALT or an EXIT instruction
+// since it was loaded or reset.
+func (e *Emulator) Halted() bool {
+	return e.halted
+}
+
+// Exited returns true if the program was halted by an EXIT instruction. Unlike
+// HALT, which is also what a program executes when it runs into zeroed memory,
+// EXIT is always a clean exit requested by the program.
+func (e *Emulator) Exited() bool {
+	return e.exited
+}
+
+// HaltReason returns the address of the HALT or EXIT instruction that halted the
+// program, and whether that address is outside of the program loaded with
+// [Emulator.Load]. Since memory is zeroed, and 0000 is a HALT instruction, a
+// program that runs off its end halts in the memory that follows it, which is
+// rarely intended. If the program hasn't halted, it returns 0 and false.
+func (e *Emulator) HaltReason() (pc uint16, ranOffEnd bool) {
+	if !e.halted {
+		return 0, false
+	}
+
+	end := ProgramStart + len(e.program)
+
+	return e.haltPC, int(e.haltPC) < ProgramStart || int(e.haltPC) >= end
+}
+
+// RegisterStats returns how many times every general-purpose register has been
+// read and written by the executed instructions. The statistics are cleared by
+// [Emulator.Reset].
+func (e *Emulator) RegisterStats() [16]RegisterStat {
+	return e.registerStats
+}
+
+// CalledAddresses returns the addresses called by CALL instructions since the
+// last call to [Emulator.Reset], in ascending order. Unlike a static call graph,
+// it includes subroutines called by code that is only reachable through JP V0,
+// addr, or by code written by the program itself.
+func (e *Emulator) CalledAddresses() []uint16 {
+	addrs := make([]uint16, 0, len(e.called))
+
+	for addr := range e.called {
+		addrs = append(addrs, addr)
+	}
+
+	slices.Sort(addrs)
+
+	return addrs
+}
+
+// ExecutedMap returns a bitmap of the memory executed since the last call to
+// [Emulator.Reset], with one bit for every byte: the byte at addr was part of an
+// executed instruction if bit addr%8 of the byte at index addr/8 is set. Since
+// the emulator can't tell code from data by looking at a rom, the bitmap tells
+// apart the bytes that were actually executed from the rest.
+func (e *Emulator) ExecutedMap() []byte {
+	return slices.Clone(e.executed[:])
+}
+
+// CollisionCount returns the number of sprites that collided with the content
+// of the display, setting VF to 1, since the last call to
+// [Emulator.ResetCollisionCount] or [Emulator.Reset].
+func (e *Emulator) CollisionCount() uint64 {
+	return e.collisions
+}
+
+// ResetCollisionCount sets the number returned by [Emulator.CollisionCount] to
+// zero.
+func (e *Emulator) ResetCollisionCount() {
+	e.collisions = 0
+}
+
+// SetTimersEnabled enables or disables the delay and sound timers. While the
+// timers are disabled, [Emulator.Clock] doesn't change them. Timers are enabled
+// by default.
+func (e *Emulator) SetTimersEnabled(enabled bool) {
+	e.timersPaused = !enabled
+}
+
+// SetCPUEnabled enables or disables the execution of instructions. While the CPU
+// is disabled, [Emulator.Step] doesn't execute any instruction and reports that
+// execution should continue. The CPU is enabled by default.
+func (e *Emulator) SetCPUEnabled(enabled bool) {
+	e.cpuPaused = !enabled
+}
+
+// SetWarnMisalignedPC enables or disables the detection of misaligned jumps.
+// While enabled, a JP, CALL, or JP V0 instruction whose target is an odd address
+// fails with an error, and the program counter is left at the instruction. This
+// usually points at a bug in the program, but some programs jump to odd addresses
+// on purpose, so detection is disabled by default.
+func (e *Emulator) SetWarnMisalignedPC(enabled bool) {
+	e.checkAlignment = enabled
+}
+
+// SetWriteAheadTrap sets the number of bytes following the current instruction
+// that the instruction is not allowed to write. An LD B, Vx or LD [I], Vx
+// instruction writing any of them fails with an error, and the program counter
+// is left at the instruction. Such a write changes an instruction that is about
+// to be executed, which is rarely intended. Pass 0, the default, to disable the
+// trap.
+func (e *Emulator) SetWriteAheadTrap(bytes int) {
+	e.writeAheadTrap = max(bytes, 0)
+}
+
+// SetMaxDrawsPerFrame limits the sprites drawn in every frame to n. Once the
+// limit is reached, DRW instructions draw nothing and leave VF unchanged until
+// the next call to [Emulator.Clock]. This protects the caller from programs that
+// draw so many sprites that the emulator can't keep up. Pass 0, the default, to
+// remove the limit.
+func (e *Emulator) SetMaxDrawsPerFrame(n int) {
+	e.maxDraws = max(n, 0)
+}
+
+// SetDrawLimitWarning registers a callback that is called with the frame count
+// the first time a DRW instruction is skipped in a frame, because of the limit
+// set by [Emulator.SetMaxDrawsPerFrame]. Pass nil to stop the warnings.
+func (e *Emulator) SetDrawLimitWarning(warn func(frame uint64)) {
+	e.drawLimit = warn
+}
+
 // SetRNG sets the random number generator used by the RND instruction. If not
 // set, the emulator uses the default source from math/rand/v2.
 func (e *Emulator) SetRNG(rng func() uint32) {
 	e.rng = rng
 }
 
+// SetQuirks sets the interpreter behaviors emulated by the following
+// instructions. See [Quirks] for the available behaviors.
+//
+// Quirks can be changed at any time, even while a program runs, and apply from
+// the next instruction. A program written for different quirks might be left
+// in an inconsistent state, for example if it stored registers with one quirk
+// and loads them back with another, so changing quirks mid-run is only meant
+// for experimenting. Call [Emulator.Reset] to start over with the new quirks.
+func (e *Emulator) SetQuirks(quirks Quirks) {
+	e.quirks = quirks
+}
+
+// SetResolution changes the size of the display to width×height pixels and
+// clears it. The default resolution is [DisplayWidth]×[DisplayHeight]. It
+// returns an error if the resolution is larger than [MaxDisplayWidth]×
+// [MaxDisplayHeight] or if any of the dimensions is not positive.
+func (e *Emulator) SetResolution(width, height int) error {
+	if width <= 0 || width > MaxDisplayWidth || height <= 0 || height > MaxDisplayHeight {
+		return fmt.Errorf("invalid resolution: %dx%d (max %dx%d)", width, height, MaxDisplayWidth, MaxDisplayHeight)
+	}
+
+	e.resize(width, height)
+	e.commitFrame()
+
+	return nil
+}
+
+// resize changes the size of the display and clears it. Unlike
+// [Emulator.SetResolution], it doesn't commit the frame, so that LOW and HIGH
+// only become visible at the end of the frame, like any other instruction.
+func (e *Emulator) resize(width, height int) {
+	e.state.Width = width
+	e.state.Height = height
+	e.state.Display = Display{}
+	e.lastDraw = drawRecord{}
+}
+
+// SetDisplay replaces the content of the display with display, as if it had
+// been drawn by the program. Every pixel holds one bit for each plane, as
+// described by [Display], and the other bits are ignored. The resolution is
+// unchanged, so only the active area of display is visible. Since
+// the display no longer results from the last sprite drawn, the sprite can't be
+// reverted with [Emulator.UndoLastDraw].
+func (e *Emulator) SetDisplay(display *Display) {
+	for y := range display {
+		for x, p := range display[y] {
+			e.state.Display[y][x] = p & AllPlanes
+		}
+	}
+
+	e.lastDraw = drawRecord{}
+}
+
+// Resolution returns the width and height of the display in pixels.
+func (e *Emulator) Resolution() (width, height int) {
+	return e.state.Width, e.state.Height
+}
+
+// DisplayInto packs the active area of the display into dst, one bit per pixel,
+// and returns the number of bytes written. Pixels are packed in row-major order,
+// and the leftmost pixel of every byte is stored in its most significant bit.
+// Unlike [Emulator.State], it doesn't copy the rest of the machine state, so
+// that the same buffer can be reused to render every frame. It returns an error
+// if dst is shorter than Width×Height/8 bytes, rounded up.
+//
+// If double buffering is enabled with [Emulator.SetDoubleBuffered], the display
+// committed at the end of the last frame is packed instead.
+func (e *Emulator) DisplayInto(dst []byte) (int, error) {
+	if n, ok, err := e.committedDisplayInto(dst); ok {
+		return n, err
+	}
+
+	n := (e.state.Width*e.state.Height + 7) / 8
+
+	if len(dst) < n {
+		return 0, fmt.Errorf("buffer too small: %d bytes, need %d", len(dst), n)
+	}
+
+	return e.packDisplay(dst), nil
+}
+
+// packDisplay packs the active area of the display into dst, which must be long
+// enough, and returns the number of bytes written.
+func (e *Emulator) packDisplay(dst []byte) int {
+	n := (e.state.Width*e.state.Height + 7) / 8
+
+	clear(dst[:n])
+
+	var i int
+
+	for y := range e.state.Height {
+		for _, p := range e.state.Display[y][:e.state.Width] {
+			if p != 0 {
+				dst[i/8] |= 0x80 >> (i % 8)
+			}
+			i++
+		}
+	}
+
+	return n
+}
+
+// Quirks returns the interpreter behaviors currently emulated, as set by
+// [Emulator.SetQuirks].
+func (e *Emulator) Quirks() Quirks {
+	return e.quirks
+}
+
+// SetFlagTracer registers a callback that is called every time an instruction
+// writes VF as a side effect. Explicit writes to VF, like LD VF, byte, are not
+// reported. Pass nil to stop tracing.
+func (e *Emulator) SetFlagTracer(tracer FlagTracer) {
+	e.flagTracer = tracer
+}
+
+// SetMemTracer registers a callback that is called every time an instruction
+// reads from or writes to memory. Fetching the instructions themselves is not
+// reported. Pass nil to stop tracing.
+func (e *Emulator) SetMemTracer(tracer MemTracer) {
+	e.memTracer = tracer
+}
+
 // SetSound registers a callback that is called once when the sound timer expires.
+// The callback can use [Emulator.AudioPattern] to play the audio pattern of
+// XO-CHIP, if the program loaded one, instead of a fixed sound.
 func (e *Emulator) SetSound(sound func()) {
 	e.sound = sound
 }
 
+// AudioPattern returns the audio pattern loaded by the program, and the rate in
+// samples per second at which it is played, which follows the pitch. It returns
+// false if the program didn't load a pattern since the last reset.
+func (e *Emulator) AudioPattern() (AudioPattern, float64, bool) {
+	rate := 4000 * math.Pow(2, (float64(e.state.Pitch)-DefaultPitch)/48)
+	return e.state.Pattern, rate, e.state.PatternLoaded
+}
+
 // Load copies program into memory starting at [ProgramStart]. It returns an
 // error if the program is too large to fit in the available memory.
 func (e *Emulator) Load(program []uint8) error {
@@ -222,15 +1017,93 @@ func (e *Emulator) Load(program []uint8) error {
 		return fmt.Errorf("program too large: %d bytes (max %d)", len(program), len(e.state.Memory)-ProgramStart)
 	}
 	copy(e.state.Memory[ProgramStart:], program)
+	e.program = append(e.program[:0], program...)
 	return nil
 }
 
+// LoadSprites copies sprites into memory one after the other, starting at base,
+// and returns the address of each sprite. It returns an error, without
+// modifying the memory, if the sprites don't fit in memory.
+func (e *Emulator) LoadSprites(base uint16, sprites [][]uint8) ([]uint16, error) {
+	var size int
+
+	for _, sprite := range sprites {
+		size += len(sprite)
+	}
+
+	if int(base)+size > len(e.state.Memory) {
+		return nil, fmt.Errorf("sprites too large: %d bytes at %03x (max %d)", size, base, len(e.state.Memory)-int(base))
+	}
+
+	addrs := make([]uint16, len(sprites))
+	addr := base
+
+	for i, sprite := range sprites {
+		addrs[i] = addr
+		for j := range sprite {
+			e.trace.recordWrite(addr+uint16(j), e.state.Memory[addr+uint16(j)])
+		}
+		copy(e.state.Memory[addr:], sprite)
+		addr += uint16(len(sprite))
+	}
+
+	return addrs, nil
+}
+
 // Step decodes and executes the instruction at the current program counter.
 // It returns true if execution should continue, or false if the emulator has
 // halted. It returns an error if the instruction is not recognized.
 func (e *Emulator) Step() (bool, error) {
+	if e.chaosRate > 0 {
+		return e.chaosStep()
+	}
+	return e.step()
+}
+
+func (e *Emulator) step() (bool, error) {
+	if e.cpuPaused {
+		return true, nil
+	}
+
+	if len(e.keyEvents) > 0 {
+		event := e.keyEvents[0]
+		e.keyEvents = e.keyEvents[1:]
+
+		if event.Down {
+			e.KeyDown(event.Key)
+		} else {
+			e.KeyUp(event.Key)
+		}
+	}
+
 	op := e.state.Instruction()
 
+	e.trace.record(e.state.PC, op, &e.state)
+
+	e.executed[e.state.PC/8] |= 1 << (e.state.PC % 8)
+	e.executed[(e.state.PC+1)/8] |= 1 << ((e.state.PC + 1) % 8)
+
+	if e.checkAlignment {
+		if target, ok := e.jumpTarget(op); ok && target%2 != 0 {
+			return false, fmt.Errorf("misaligned jump: %04x at %04x jumps to %04x", op, e.state.PC, target)
+		}
+	}
+
+	if e.writeAheadTrap > 0 {
+		if start, end, ok := e.writeRange(op); ok {
+			ahead := int(e.state.PC) + 2
+
+			if int(start) < ahead+e.writeAheadTrap && int(end) > ahead {
+				return false, fmt.Errorf("write ahead of pc: %04x at %04x writes %04x-%04x", op, e.state.PC, start, end-1)
+			}
+		}
+	}
+
+	if handler := e.opcodeHandler(op); handler != nil {
+		e.runHandler(handler, op)
+		return true, nil
+	}
+
 	// The opcode 0NNN jumps to a machine code routine at address NNN, but it is
 	// only used on the computers on which CHIP-8 was implemented. This
 	// interpreter implements an opcode of this form as a HALT instruction.
@@ -242,10 +1115,28 @@ func (e *Emulator) Step() (bool, error) {
 			e.clearDisplay()
 		case OpRET:
 			e.functionReturn()
-		case OpHALT:
+		case OpSCR:
+			e.scrollRight()
+		case OpSCL:
+			e.scrollLeft()
+		case OpLOW:
+			e.switchResolution(DisplayWidth, DisplayHeight)
+		case OpHIGH:
+			e.switchResolution(MaxDisplayWidth, MaxDisplayHeight)
+		case OpHALT, OpEXIT:
+			e.halted = true
+			e.haltPC = e.state.PC
+			e.exited = op&MaskKK == OpEXIT
 			return false, nil
 		default:
-			return false, fmt.Errorf("invalid opcode: %04x", op)
+			switch op & MaskKK &^ MaskN {
+			case OpSCD:
+				e.scrollDown(op)
+			case OpSCU:
+				e.scrollUp(op)
+			default:
+				return false, fmt.Errorf("invalid opcode: %04x", op)
+			}
 		}
 	case OpTypeJP:
 		e.jump(op)
@@ -305,6 +1196,12 @@ func (e *Emulator) Step() (bool, error) {
 		}
 	case OpTypeMisc:
 		switch op & MaskKK {
+		case OpLDIL:
+			e.loadIndexLong()
+		case OpPLANE:
+			e.selectPlanes(op)
+		case OpAUDIO:
+			e.loadAudioPattern()
 		case OpLDVDT:
 			e.loadRegisterFromDelayTimer(op)
 		case OpLDVK:
@@ -317,12 +1214,20 @@ func (e *Emulator) Step() (bool, error) {
 			e.incrementIndex(op)
 		case OpLDF:
 			e.loadIndexFromSprite(op)
+		case OpLDHF:
+			e.loadIndexFromLargeSprite(op)
 		case OpLDB:
 			e.loadMemoryFromBCD(op)
+		case OpPITCH:
+			e.setPitch(op)
 		case OpSTMV:
 			e.loadMemoryFromRegisters(op)
 		case OpLDVM:
 			e.loadRegistersFromMemory(op)
+		case OpLDRV:
+			e.loadFlagsFromRegisters(op)
+		case OpLDVR:
+			e.loadRegistersFromFlags(op)
 		default:
 			return false, fmt.Errorf("invalid opcode: %04x", op)
 		}
@@ -331,8 +1236,196 @@ func (e *Emulator) Step() (bool, error) {
 	return true, nil
 }
 
+// setFlag writes value to VF as a side effect of op, and reports the write to
+// the flag tracer.
+func (e *Emulator) setFlag(op uint16, value uint8, cause FlagCause) {
+	e.writeRegister(0xf, value)
+
+	if e.flagTracer != nil {
+		e.flagTracer(op, value, cause)
+	}
+}
+
+// readRegister returns the value of the register Vx, and counts the read in the
+// register statistics.
+func (e *Emulator) readRegister(x uint16) uint8 {
+	e.registerStats[x].Reads++
+	return e.state.V[x]
+}
+
+// writeRegister stores value in the register Vx, and counts the write in the
+// register statistics.
+func (e *Emulator) writeRegister(x uint16, value uint8) {
+	e.registerStats[x].Writes++
+	e.state.V[x] = value
+}
+
+// readMemory returns the byte at addr, and reports the read to the memory
+// tracer.
+func (e *Emulator) readMemory(addr uint16) uint8 {
+	value := e.state.Memory[addr]
+
+	if e.memTracer != nil {
+		e.memTracer(addr, false, value)
+	}
+
+	return value
+}
+
+// writeMemory stores value at addr, and reports the write to the memory tracer.
+func (e *Emulator) writeMemory(addr uint16, value uint8) {
+	e.trace.recordWrite(addr, e.state.Memory[addr])
+	e.state.Memory[addr] = value
+
+	if e.memTracer != nil {
+		e.memTracer(addr, true, value)
+	}
+}
+
+// StepUntil executes instructions until pred returns true for the machine state
+// after an instruction, or until maxSteps instructions have been executed. It
+// returns whether pred matched, and the number of executed instructions. It
+// also stops, without a match, if the emulator halts or an instruction fails.
+// Since a failing instruction doesn't change the program counter, calling
+// [Emulator.Step] afterwards reports the error.
+//
+// To avoid a copy of the state after every instruction, pred receives the state
+// of the emulator itself. It must not modify the state, nor keep it after it
+// returns.
+func (e *Emulator) StepUntil(pred func(*State) bool, maxSteps int) (matched bool, steps int) {
+	for steps < maxSteps {
+		ok, err := e.Step()
+		if err != nil || !ok {
+			return false, steps
+		}
+
+		steps++
+
+		if pred(&e.state) {
+			return true, steps
+		}
+	}
+
+	return false, steps
+}
+
+// clearDisplay only clears the selected planes, like XO-CHIP.
 func (e *Emulator) clearDisplay() {
-	e.state.Display = Display{}
+	planes := e.state.Planes
+
+	if planes == AllPlanes {
+		e.state.Display = Display{}
+	} else {
+		for y := range e.state.Display {
+			for x := range e.state.Display[y] {
+				e.state.Display[y][x] &^= planes
+			}
+		}
+	}
+
+	e.lastDraw = drawRecord{}
+	e.state.PC += 2
+}
+
+// switchResolution implements LOW and HIGH. Like SUPER-CHIP, it clears the
+// display, even if the resolution doesn't change.
+func (e *Emulator) switchResolution(width, height int) {
+	e.resize(width, height)
+	e.state.PC += 2
+}
+
+// scrollAmount returns the pixels moved by a scroll of n pixels. Like SUPER-CHIP
+// 1.1, scrolls are measured in pixels of the high resolution, so they move half
+// as many pixels in the low resolution, unless the scroll mode is the one of
+// SUPER-CHIP 1.0.
+func (e *Emulator) scrollAmount(n int) int {
+	if e.state.Width < MaxDisplayWidth && e.quirks.Scroll == ScrollSCHIP11 {
+		return n / 2
+	}
+	return n
+}
+
+// scrollPixel replaces the selected planes of the pixel at (x, y) with the ones
+// of src, which is 0 for the pixels scrolled in from outside of the display.
+// Like XO-CHIP, scrolls only move the selected planes.
+func (e *Emulator) scrollPixel(x, y int, src uint8) {
+	planes := e.state.Planes
+	e.state.Display[y][x] = e.state.Display[y][x]&^planes | src&planes
+}
+
+func (e *Emulator) scrollDown(op uint16) {
+	n := e.scrollAmount(int(op & MaskN))
+
+	for y := e.state.Height - 1; y >= 0; y-- {
+		for x := range e.state.Width {
+			var src uint8
+
+			if y >= n {
+				src = e.state.Display[y-n][x]
+			}
+
+			e.scrollPixel(x, y, src)
+		}
+	}
+
+	e.lastDraw = drawRecord{}
+	e.state.PC += 2
+}
+
+func (e *Emulator) scrollUp(op uint16) {
+	n := e.scrollAmount(int(op & MaskN))
+
+	for y := range e.state.Height {
+		for x := range e.state.Width {
+			var src uint8
+
+			if y+n < e.state.Height {
+				src = e.state.Display[y+n][x]
+			}
+
+			e.scrollPixel(x, y, src)
+		}
+	}
+
+	e.lastDraw = drawRecord{}
+	e.state.PC += 2
+}
+
+func (e *Emulator) scrollRight() {
+	n := e.scrollAmount(4)
+
+	for y := range e.state.Height {
+		for x := e.state.Width - 1; x >= 0; x-- {
+			var src uint8
+
+			if x >= n {
+				src = e.state.Display[y][x-n]
+			}
+
+			e.scrollPixel(x, y, src)
+		}
+	}
+
+	e.lastDraw = drawRecord{}
+	e.state.PC += 2
+}
+
+func (e *Emulator) scrollLeft() {
+	n := e.scrollAmount(4)
+
+	for y := range e.state.Height {
+		for x := range e.state.Width {
+			var src uint8
+
+			if x+n < e.state.Width {
+				src = e.state.Display[y][x+n]
+			}
+
+			e.scrollPixel(x, y, src)
+		}
+	}
+
+	e.lastDraw = drawRecord{}
 	e.state.PC += 2
 }
 
@@ -342,6 +1435,42 @@ func (e *Emulator) functionReturn() {
 	e.state.PC += 2
 }
 
+// jumpTarget returns the address op transfers control to, if op is a JP, CALL,
+// or JP V0 instruction.
+func (e *Emulator) jumpTarget(op uint16) (uint16, bool) {
+	switch op & MaskFamily {
+	case OpTypeJP, OpTypeCALL:
+		return op & MaskNNN, true
+	case OpTypeJPV:
+		var x uint16
+
+		if e.quirks.JumpUsesVX {
+			x = (op & MaskX) >> ShiftX
+		}
+
+		return uint16(e.state.V[x]) + op&MaskNNN, true
+	default:
+		return 0, false
+	}
+}
+
+// writeRange returns the range of memory [start, end) written by op, if op is
+// an LD B, Vx or LD [I], Vx instruction.
+func (e *Emulator) writeRange(op uint16) (uint16, uint16, bool) {
+	if op&MaskFamily != OpTypeMisc {
+		return 0, 0, false
+	}
+
+	switch op & MaskKK {
+	case OpLDB:
+		return e.state.I, e.state.I + 3, true
+	case OpSTMV:
+		return e.state.I, e.state.I + (op&MaskX)>>ShiftX + 1, true
+	default:
+		return 0, 0, false
+	}
+}
+
 func (e *Emulator) jump(op uint16) {
 	e.state.PC = op & MaskNNN
 }
@@ -350,14 +1479,32 @@ func (e *Emulator) functionCall(op uint16) {
 	e.state.Stack[e.state.SP] = e.state.PC
 	e.state.SP++
 	e.state.PC = op & MaskNNN
+
+	if e.called == nil {
+		e.called = make(map[uint16]bool)
+	}
+
+	e.called[e.state.PC] = true
+}
+
+// skipNext skips the instruction following the current one. Like XO-CHIP, it
+// skips LD I, long addr as a whole, including the address that follows it.
+func (e *Emulator) skipNext() {
+	e.state.PC += 2
+
+	if e.state.Instruction() == OpTypeMisc|OpLDIL {
+		e.state.PC += 4
+	} else {
+		e.state.PC += 2
+	}
 }
 
 func (e *Emulator) skipIfConstantEqual(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	n := uint8(op & MaskKK)
 
-	if e.state.V[x] == n {
-		e.state.PC += 4
+	if e.readRegister(x) == n {
+		e.skipNext()
 	} else {
 		e.state.PC += 2
 	}
@@ -367,8 +1514,8 @@ func (e *Emulator) skipIfConstantNotEqual(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	n := uint8(op & MaskKK)
 
-	if e.state.V[x] != n {
-		e.state.PC += 4
+	if e.readRegister(x) != n {
+		e.skipNext()
 	} else {
 		e.state.PC += 2
 	}
@@ -378,8 +1525,8 @@ func (e *Emulator) skipIfRegisterEqual(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	y := (op & MaskY) >> ShiftY
 
-	if e.state.V[x] == e.state.V[y] {
-		e.state.PC += 4
+	if e.readRegister(x) == e.readRegister(y) {
+		e.skipNext()
 	} else {
 		e.state.PC += 2
 	}
@@ -388,45 +1535,51 @@ func (e *Emulator) skipIfRegisterEqual(op uint16) {
 func (e *Emulator) loadRegisterFromConstant(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	v := uint8(op & MaskKK)
-	e.state.V[x] = v
+	e.writeRegister(x, v)
 	e.state.PC += 2
 }
 
 func (e *Emulator) incrementRegister(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	v := uint8(op & MaskKK)
-	e.state.V[x] += v
+	e.writeRegister(x, e.readRegister(x)+v)
 	e.state.PC += 2
 }
 
 func (e *Emulator) loadRegisterFromRegister(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	y := (op & MaskY) >> ShiftY
-	e.state.V[x] = e.state.V[y]
+	e.writeRegister(x, e.readRegister(y))
 	e.state.PC += 2
 }
 
 func (e *Emulator) bitwiseOr(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	y := (op & MaskY) >> ShiftY
-	e.state.V[x] |= e.state.V[y]
-	e.state.V[0xf] = 0
+	e.writeRegister(x, e.readRegister(x)|e.readRegister(y))
+	if e.quirks.LogicResetsVF {
+		e.setFlag(op, 0, FlagLogic)
+	}
 	e.state.PC += 2
 }
 
 func (e *Emulator) bitwiseAnd(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	y := (op & MaskY) >> ShiftY
-	e.state.V[x] &= e.state.V[y]
-	e.state.V[0xf] = 0
+	e.writeRegister(x, e.readRegister(x)&e.readRegister(y))
+	if e.quirks.LogicResetsVF {
+		e.setFlag(op, 0, FlagLogic)
+	}
 	e.state.PC += 2
 }
 
 func (e *Emulator) bitwiseXor(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	y := (op & MaskY) >> ShiftY
-	e.state.V[x] ^= e.state.V[y]
-	e.state.V[0xf] = 0
+	e.writeRegister(x, e.readRegister(x)^e.readRegister(y))
+	if e.quirks.LogicResetsVF {
+		e.setFlag(op, 0, FlagLogic)
+	}
 	e.state.PC += 2
 }
 
@@ -434,18 +1587,21 @@ func (e *Emulator) addWithCarry(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	y := (op & MaskY) >> ShiftY
 
+	vx := e.readRegister(x)
+	vy := e.readRegister(y)
+
 	var carry bool
 
-	if e.state.V[x] > 0xff-e.state.V[y] {
+	if vx > 0xff-vy {
 		carry = true
 	}
 
-	e.state.V[x] += e.state.V[y]
+	e.writeRegister(x, vx+vy)
 
 	if carry {
-		e.state.V[0xf] = 1
+		e.setFlag(op, 1, FlagCarry)
 	} else {
-		e.state.V[0xf] = 0
+		e.setFlag(op, 0, FlagCarry)
 	}
 
 	e.state.PC += 2
@@ -455,18 +1611,21 @@ func (e *Emulator) subtractRightWithBorrow(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	y := (op & MaskY) >> ShiftY
 
+	vx := e.readRegister(x)
+	vy := e.readRegister(y)
+
 	var noBorrow bool
 
-	if e.state.V[x] >= e.state.V[y] {
+	if vx >= vy {
 		noBorrow = true
 	}
 
-	e.state.V[x] -= e.state.V[y]
+	e.writeRegister(x, vx-vy)
 
 	if noBorrow {
-		e.state.V[0xf] = 1
+		e.setFlag(op, 1, FlagBorrow)
 	} else {
-		e.state.V[0xf] = 0
+		e.setFlag(op, 0, FlagBorrow)
 	}
 
 	e.state.PC += 2
@@ -476,18 +1635,21 @@ func (e *Emulator) subtractLeftWithBorrow(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	y := (op & MaskY) >> ShiftY
 
+	vx := e.readRegister(x)
+	vy := e.readRegister(y)
+
 	var noBorrow bool
 
-	if e.state.V[y] >= e.state.V[x] {
+	if vy >= vx {
 		noBorrow = true
 	}
 
-	e.state.V[x] = e.state.V[y] - e.state.V[x]
+	e.writeRegister(x, vy-vx)
 
 	if noBorrow {
-		e.state.V[0xf] = 1
+		e.setFlag(op, 1, FlagBorrow)
 	} else {
-		e.state.V[0xf] = 0
+		e.setFlag(op, 0, FlagBorrow)
 	}
 
 	e.state.PC += 2
@@ -497,20 +1659,26 @@ func (e *Emulator) shiftRight(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	y := (op & MaskY) >> ShiftY
 
-	e.state.V[x] = e.state.V[y]
+	var v uint8
+
+	if e.quirks.Shift == ShiftVIPCopyVY {
+		v = e.readRegister(y)
+	} else {
+		v = e.readRegister(x)
+	}
 
 	var carry bool
 
-	if e.state.V[x]&0x01 != 0 {
+	if v&0x01 != 0 {
 		carry = true
 	}
 
-	e.state.V[x] >>= 1
+	e.writeRegister(x, v>>1)
 
 	if carry {
-		e.state.V[0xf] = 1
+		e.setFlag(op, 1, FlagShift)
 	} else {
-		e.state.V[0xf] = 0
+		e.setFlag(op, 0, FlagShift)
 	}
 
 	e.state.PC += 2
@@ -520,20 +1688,26 @@ func (e *Emulator) shiftLeft(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	y := (op & MaskY) >> ShiftY
 
-	e.state.V[x] = e.state.V[y]
+	var v uint8
+
+	if e.quirks.Shift == ShiftVIPCopyVY {
+		v = e.readRegister(y)
+	} else {
+		v = e.readRegister(x)
+	}
 
 	var carry bool
 
-	if e.state.V[x]&0x80 != 0 {
+	if v&0x80 != 0 {
 		carry = true
 	}
 
-	e.state.V[x] <<= 1
+	e.writeRegister(x, v<<1)
 
 	if carry {
-		e.state.V[0xf] = 1
+		e.setFlag(op, 1, FlagShift)
 	} else {
-		e.state.V[0xf] = 0
+		e.setFlag(op, 0, FlagShift)
 	}
 
 	e.state.PC += 2
@@ -543,8 +1717,8 @@ func (e *Emulator) skipIfRegisterNotEqual(op uint16) {
 	x := (op & MaskX) >> ShiftX
 	y := (op & MaskY) >> ShiftY
 
-	if e.state.V[x] != e.state.V[y] {
-		e.state.PC += 4
+	if e.readRegister(x) != e.readRegister(y) {
+		e.skipNext()
 	} else {
 		e.state.PC += 2
 	}
@@ -558,7 +1732,16 @@ func (e *Emulator) loadIndex(op uint16) {
 
 func (e *Emulator) jumpRelative(op uint16) {
 	n := op & MaskNNN
-	e.state.PC = uint16(e.state.V[0]) + n
+
+	var x uint16
+
+	if e.quirks.JumpUsesVX {
+		x = (op & MaskX) >> ShiftX
+	}
+
+	// The target isn't masked to 12 bits, so it can be past 0xFFF. It can't be
+	// past the end of the memory, since 0xFF + 0xFFF is less than 64KB.
+	e.state.PC = uint16(e.readRegister(x)) + n
 }
 
 func (e *Emulator) generateRandomNumber(op uint16) {
@@ -573,7 +1756,7 @@ func (e *Emulator) generateRandomNumber(op uint16) {
 		r = rand.Uint32()
 	}
 
-	e.state.V[x] = uint8(r) & uint8(n)
+	e.writeRegister(x, uint8(r)&uint8(n))
 	e.state.PC += 2
 }
 
@@ -582,46 +1765,196 @@ func (e *Emulator) draw(op uint16) {
 	y := (op & MaskY) >> ShiftY
 	n := op & MaskN
 
-	e.state.V[0xf] = 0
+	// With the display wait quirk, only one sprite can be drawn in every frame.
+	// Leaving the program counter unchanged executes this instruction again
+	// until the next call to Clock() increments the frame count, which models
+	// the vertical blank interrupt of the COSMAC VIP.
+
+	if e.quirks.DisplayWait {
+		if e.drawn && e.drawFrame == e.state.FrameCount {
+			return
+		}
+		e.drawn = true
+		e.drawFrame = e.state.FrameCount
+	}
+
+	if e.maxDraws > 0 && e.state.DrawsThisFrame >= e.maxDraws {
+		e.drawsSkipped++
+		if e.drawsSkipped == 1 && e.drawLimit != nil {
+			e.drawLimit(e.state.FrameCount)
+		}
+		e.state.PC += 2
+		return
+	}
+
+	bx := int(e.readRegister(x)) % e.state.Width
+	by := int(e.readRegister(y)) % e.state.Height
+
+	// A sprite of height 0 is not defined by the original interpreter, and
+	// draws nothing. SUPER-CHIP uses it for a 16×16 sprite, stored in 32 bytes
+	// with two bytes per row.
+
+	large := n == 0 && e.quirks.LargeSprites
+
+	size := n
+
+	if large {
+		size = 2 * LargeSpriteSize
+	}
 
-	bx := e.state.V[x] % DisplayWidth
-	by := e.state.V[y] % DisplayHeight
+	// When both planes are selected, the sprite of the second plane follows
+	// the one of the first plane in memory.
 
-	for dy := range n {
-		sprite := e.state.Memory[e.state.I+dy]
+	planes := e.state.Planes
 
-		py := int(by) + int(dy)
+	var sprite [4 * LargeSpriteSize]uint8
 
-		if py >= DisplayHeight {
-			break
+	for i := range size * uint16(bits.OnesCount8(planes)) {
+		sprite[i] = e.readMemory(e.state.I + i)
+	}
+
+	e.state.DrawsThisFrame++
+
+	e.lastDraw = drawRecord{
+		x:      bx,
+		y:      by,
+		sprite: sprite,
+		height: uint8(n),
+		large:  large,
+		planes: planes,
+		vf:     e.state.V[0xf],
+		valid:  true,
+	}
+
+	collision := e.xorPlanes(bx, by, sprite[:], int(n), large, planes)
+
+	if collision {
+		e.collisions++
+		e.setFlag(op, 1, FlagCollision)
+	} else {
+		e.setFlag(op, 0, FlagCollision)
+	}
+
+	e.state.PC += 2
+}
+
+// xorPlanes draws sprite in each of the given planes, reading the rows for every
+// plane after the ones for the previous plane. A sprite is made of height rows,
+// or is 16×16 if large is true. It returns true if there was a collision in any
+// plane.
+func (e *Emulator) xorPlanes(bx, by int, sprite []uint8, height int, large bool, planes uint8) bool {
+	var collision bool
+
+	if large {
+		height = 2 * LargeSpriteSize
+	}
+
+	for plane := uint8(1); plane <= AllPlanes; plane <<= 1 {
+		if planes&plane == 0 {
+			continue
 		}
 
-		for dx := range SpriteWidth {
-			px := int(bx) + dx
+		var c bool
+
+		if large {
+			c = e.xorLargeSprite(bx, by, sprite[:height], plane)
+		} else {
+			c = e.xorSprite(bx, by, sprite[:height], plane)
+		}
+
+		collision = collision || c
+		sprite = sprite[height:]
+	}
+
+	return collision
+}
 
-			if px >= DisplayWidth {
+// xorLargeSprite draws a 16×16 sprite, stored with two bytes per row, in the
+// given plane of the display, as two sprites 8 pixels wide side by side.
+func (e *Emulator) xorLargeSprite(bx, by int, sprite []uint8, plane uint8) bool {
+	var left, right [LargeSpriteSize]uint8
+
+	for dy := range LargeSpriteSize {
+		left[dy] = sprite[2*dy]
+		right[dy] = sprite[2*dy+1]
+	}
+
+	collision := e.xorSprite(bx, by, left[:], plane)
+
+	if e.xorSprite(bx+SpriteWidth, by, right[:], plane) {
+		collision = true
+	}
+
+	return collision
+}
+
+// xorSprite draws sprite in the given plane of the display, with its top-left
+// corner at (bx, by). The pixels past the right and bottom edges of the display
+// are clipped, or drawn at the opposite edge with the wrap quirk. It returns true
+// if any pixel of the plane that was on has been turned off.
+func (e *Emulator) xorSprite(bx, by int, sprite []uint8, plane uint8) bool {
+	var collision bool
+
+	for dy, row := range sprite {
+		py := by + dy
+
+		// Sprites are clipped at the bottom and at the right edge of the
+		// display, unless the wrap quirk draws the rest at the opposite edge.
+
+		if py >= e.state.Height {
+			if !e.quirks.WrapSprites {
 				break
 			}
+			py -= e.state.Height
+		}
+
+		// Fast path: when the row is entirely visible, the eight pixels covered by
+		// the row are updated at once, as a single 64-bit word where every byte
+		// is a pixel.
+
+		if bx+SpriteWidth <= e.state.Width {
+			pixels := e.state.Display[py][bx : bx+SpriteWidth]
+			word := binary.LittleEndian.Uint64(pixels)
+			mask := spreadRows[row] * uint64(plane)
+
+			if word&mask != 0 {
+				collision = true
+			}
+
+			binary.LittleEndian.PutUint64(pixels, word^mask)
+
+			continue
+		}
+
+		for dx := range SpriteWidth {
+			px := bx + dx
+
+			if px >= e.state.Width {
+				if !e.quirks.WrapSprites {
+					break
+				}
+				px -= e.state.Width
+			}
 
-			if bit := sprite & (0x80 >> dx); bit != 0 {
-				if e.state.Display[py][px] != 0 {
-					e.state.V[0xf] = 1
+			if bit := row & (0x80 >> dx); bit != 0 {
+				if e.state.Display[py][px]&plane != 0 {
+					collision = true
 				}
 
-				e.state.Display[py][px] ^= 1
+				e.state.Display[py][px] ^= plane
 			}
 		}
 	}
 
-	e.state.PC += 2
+	return collision
 }
 
 func (e *Emulator) skipIfKeyPressed(op uint16) {
 	x := (op & MaskX) >> ShiftX
-	k := e.state.V[x] & 0xf
+	k := e.readRegister(x) & 0xf
 
 	if e.state.Keys[k] {
-		e.state.PC += 4
+		e.skipNext()
 	} else {
 		e.state.PC += 2
 	}
@@ -629,18 +1962,41 @@ func (e *Emulator) skipIfKeyPressed(op uint16) {
 
 func (e *Emulator) skipIfKeyNotPressed(op uint16) {
 	x := (op & MaskX) >> ShiftX
-	k := e.state.V[x] & 0xf
+	k := e.readRegister(x) & 0xf
 
 	if e.state.Keys[k] {
 		e.state.PC += 2
 	} else {
-		e.state.PC += 4
+		e.skipNext()
 	}
 }
 
+// loadIndexLong loads I from the two bytes following the instruction, which are
+// fetched as part of it.
+func (e *Emulator) loadIndexLong() {
+	addr := e.state.PC + 2
+
+	e.executed[addr/8] |= 1 << (addr % 8)
+	e.executed[(addr+1)/8] |= 1 << ((addr + 1) % 8)
+
+	e.state.I = uint16(e.state.Memory[addr])<<8 | uint16(e.state.Memory[addr+1])
+	e.state.PC += 4
+}
+
+func (e *Emulator) selectPlanes(op uint16) {
+	e.state.Planes = uint8((op&MaskX)>>ShiftX) & AllPlanes
+	e.state.PC += 2
+}
+
+func (e *Emulator) loadAudioPattern() {
+	copy(e.state.Pattern[:], e.state.Memory[e.state.I:])
+	e.state.PatternLoaded = true
+	e.state.PC += 2
+}
+
 func (e *Emulator) loadRegisterFromDelayTimer(op uint16) {
 	x := (op & MaskX) >> ShiftX
-	e.state.V[x] = e.state.DT
+	e.writeRegister(x, e.state.DT)
 	e.state.PC += 2
 }
 
@@ -652,54 +2008,103 @@ func (e *Emulator) waitForKeyPress(op uint16) {
 
 func (e *Emulator) loadDelayTimer(op uint16) {
 	x := (op & MaskX) >> ShiftX
-	e.state.DT = e.state.V[x]
+	e.state.DT = e.readRegister(x)
 	e.state.PC += 2
 }
 
 func (e *Emulator) loadSoundTimer(op uint16) {
 	x := (op & MaskX) >> ShiftX
-	e.state.ST = e.state.V[x]
+	e.state.ST = e.readRegister(x)
 	e.state.PC += 2
 }
 
 func (e *Emulator) incrementIndex(op uint16) {
 	x := (op & MaskX) >> ShiftX
-	e.state.I += uint16(e.state.V[x])
+	e.state.I += uint16(e.readRegister(x))
 	e.state.PC += 2
 }
 
 func (e *Emulator) loadIndexFromSprite(op uint16) {
 	x := (op & MaskX) >> ShiftX
-	e.state.I = uint16(FontSize * e.state.V[x])
+	e.state.I = uint16(FontSize * e.readRegister(x))
+	e.state.PC += 2
+}
+
+// loadIndexFromLargeSprite only uses the low nibble of Vx, like Octo, so that I
+// always points into the large font.
+func (e *Emulator) loadIndexFromLargeSprite(op uint16) {
+	x := (op & MaskX) >> ShiftX
+	e.state.I = uint16(LargeFontStart + LargeFontSize*int(e.readRegister(x)&0xf))
 	e.state.PC += 2
 }
 
 func (e *Emulator) loadMemoryFromBCD(op uint16) {
 	x := (op & MaskX) >> ShiftX
-	e.state.Memory[e.state.I] = e.state.V[x] / 100
-	e.state.Memory[e.state.I+1] = (e.state.V[x] % 100) / 10
-	e.state.Memory[e.state.I+2] = e.state.V[x] % 10
+	v := e.readRegister(x)
+	e.writeMemory(e.state.I, v/100)
+	e.writeMemory(e.state.I+1, (v%100)/10)
+	e.writeMemory(e.state.I+2, v%10)
 	e.state.PC += 2
 }
 
 func (e *Emulator) loadMemoryFromRegisters(op uint16) {
 	x := (op & MaskX) >> ShiftX
+	i := e.state.I
 
 	for n := range x + 1 {
-		e.state.Memory[e.state.I] = e.state.V[n]
+		e.writeMemory(e.state.I, e.readRegister(n))
 		e.state.I++
 	}
 
+	if !e.quirks.MemoryIncrementsI {
+		e.state.I = i
+	}
+
 	e.state.PC += 2
 }
 
 func (e *Emulator) loadRegistersFromMemory(op uint16) {
 	x := (op & MaskX) >> ShiftX
+	i := e.state.I
 
 	for n := range x + 1 {
-		e.state.V[n] = e.state.Memory[e.state.I]
+		e.writeRegister(n, e.readMemory(e.state.I))
 		e.state.I++
 	}
 
+	if !e.quirks.MemoryIncrementsI {
+		e.state.I = i
+	}
+
+	e.state.PC += 2
+}
+
+// setPitch sets the pitch of the audio pattern to Vx. See [DefaultPitch] for how
+// the pitch maps to the rate at which the pattern is played.
+func (e *Emulator) setPitch(op uint16) {
+	x := (op & MaskX) >> ShiftX
+	e.state.Pitch = e.readRegister(x)
+	e.state.PC += 2
+}
+
+// loadFlagsFromRegisters stores at most 8 registers, since there are only 8 RPL
+// flags. The same goes for loadRegistersFromFlags.
+func (e *Emulator) loadFlagsFromRegisters(op uint16) {
+	x := (op & MaskX) >> ShiftX
+
+	for n := range min(x, 7) + 1 {
+		e.state.Flags[n] = e.readRegister(n)
+	}
+
+	e.state.PC += 2
+}
+
+func (e *Emulator) loadRegistersFromFlags(op uint16) {
+	x := (op & MaskX) >> ShiftX
+
+	for n := range min(x, 7) + 1 {
+		e.writeRegister(n, e.state.Flags[n])
+	}
+
 	e.state.PC += 2
 }
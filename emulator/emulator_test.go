@@ -1,6 +1,12 @@
 package emulator_test
 
 import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/francescomari/chip-8/emulator"
@@ -225,6 +231,48 @@ func TestAddOverflowRegister(t *testing.T) {
 		register(0xf, 0x00)
 }
 
+func TestFlagTracer(t *testing.T) {
+	e := emulator.New()
+
+	type write struct {
+		op    uint16
+		value uint8
+		cause emulator.FlagCause
+	}
+
+	var writes []write
+
+	e.SetFlagTracer(func(op uint16, value uint8, cause emulator.FlagCause) {
+		writes = append(writes, write{op, value, cause})
+	})
+
+	if err := e.Load([]uint8{
+		0x60, 0x0f, // LD V0, 0x0f
+		0x61, 0xff, // LD V1, 0xff
+		0x81, 0x04, // ADD V1, V0
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for {
+		ok, err := e.Step()
+		if err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		if !ok {
+			break
+		}
+	}
+
+	if len(writes) != 1 {
+		t.Fatalf("writes: got %d, want 1", len(writes))
+	}
+
+	if want := (write{0x8104, 1, emulator.FlagCarry}); writes[0] != want {
+		t.Fatalf("write: got %+v, want %+v", writes[0], want)
+	}
+}
+
 func TestSub(t *testing.T) {
 	e := run(t,
 		0x60, 0x01, // LD V0, 0x01
@@ -456,6 +504,120 @@ func TestCallAndReturn(t *testing.T) {
 		register(0x1, 0x01)
 }
 
+func TestExecutedMap(t *testing.T) {
+	e := run(t,
+		0x60, 0x01, // LD V0, 0x01
+		0x30, 0x01, // SE V0, 0x01
+		0x60, 0x02, // LD V0, 0x02, skipped
+		0x12, 0x0a, // JP 0x20a
+		0xff, 0xff, // Data
+		0x00, 0x00, // HALT
+	)
+
+	want := map[int]bool{
+		0x200: true, 0x201: true,
+		0x202: true, 0x203: true,
+		0x206: true, 0x207: true,
+		0x20a: true, 0x20b: true,
+	}
+
+	executed := e.ExecutedMap()
+
+	for addr := range len(emulator.Memory{}) {
+		if got := executed[addr/8]&(1<<(addr%8)) != 0; got != want[addr] {
+			t.Fatalf("address %04x: got executed %v, want %v", addr, got, want[addr])
+		}
+	}
+
+	e.Reset()
+
+	for i, b := range e.ExecutedMap() {
+		if b != 0 {
+			t.Fatalf("byte %d after reset: got %08b, want 0", i, b)
+		}
+	}
+}
+
+func TestCalledAddresses(t *testing.T) {
+	e := run(t,
+		0x22, 0x10, // CALL 0x210
+		0x60, 0x02, // LD V0, 0x02
+		0xb2, 0x08, // JP V0, 0x208
+		0x00, 0x00, // HALT
+		0x00, 0x00, // HALT
+		0x22, 0x12, // CALL 0x212
+		0x00, 0x00, // HALT
+		0x00, 0x00, // HALT
+		0x00, 0xee, // RET
+		0x22, 0x10, // CALL 0x210
+		0x00, 0xee, // RET
+	)
+
+	// The call to 0x212 is only reachable through JP V0, addr, and the
+	// subroutine at 0x210 is recorded once, although it is called twice.
+
+	if got, want := e.CalledAddresses(), []uint16{0x210, 0x212}; !slices.Equal(got, want) {
+		t.Fatalf("called: got %03x, want %03x", got, want)
+	}
+
+	e.Reset()
+
+	if got := e.CalledAddresses(); len(got) != 0 {
+		t.Fatalf("called after reset: got %03x", got)
+	}
+}
+
+func TestStackAccessors(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0x22, 0x04, // CALL 0x204
+		0x00, 0x00, // HALT
+		0x22, 0x08, // CALL 0x208
+		0x00, 0xee, // RET
+		0x00, 0xee, // RET
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if _, ok := e.StackTop(); ok {
+		t.Fatal("stack should be empty")
+	}
+
+	var state emulator.State
+
+	for _, want := range [][]uint16{
+		{0x200},
+		{0x200, 0x204},
+		{0x200},
+		{},
+	} {
+		if _, err := e.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+
+		e.State(&state)
+
+		stack := e.StackSlice()
+
+		if len(stack) != int(state.SP) {
+			t.Fatalf("stack length: got %d, want SP = %d", len(stack), state.SP)
+		}
+		if !slices.Equal(stack, want) {
+			t.Fatalf("stack: got %04x, want %04x", stack, want)
+		}
+
+		top, ok := e.StackTop()
+
+		if ok != (len(want) > 0) {
+			t.Fatalf("stack top: got ok = %v with stack %04x", ok, want)
+		}
+		if ok && top != want[len(want)-1] {
+			t.Fatalf("stack top: got %04x, want %04x", top, want[len(want)-1])
+		}
+	}
+}
+
 func TestJumpRelative(t *testing.T) {
 	e := run(t,
 		0x60, 0x04, // LD V0, 0x04
@@ -466,7 +628,203 @@ func TestJumpRelative(t *testing.T) {
 
 	check(t, e).
 		register(0x0, 0x04).
-		register(0x0, 0x04)
+		register(0x1, 0x01)
+}
+
+func TestJumpRelativePastTwelveBits(t *testing.T) {
+	program := make([]uint8, 0x1100-emulator.ProgramStart)
+
+	copy(program, []uint8{
+		0x60, 0xff, // LD V0, 0xff
+		0xbf, 0xff, // JP V0, 0xfff
+	})
+
+	// The target, 0x10fe, is not masked to 0x0fe.
+
+	copy(program[0x10fe-emulator.ProgramStart:], []uint8{
+		0x61, 0x01, // LD V1, 0x01
+	})
+
+	e := run(t, program...)
+
+	var state emulator.State
+	e.State(&state)
+
+	if state.PC != 0x1100 {
+		t.Fatalf("pc: got %04x, want 1100", state.PC)
+	}
+
+	check(t, e).register(0x1, 0x01)
+}
+
+func TestWarnMisalignedPC(t *testing.T) {
+	program := []uint8{
+		0x60, 0x02, // LD V0, 0x02
+		0xb2, 0x03, // JP V0, 0x203
+		0x00, 0x00, // HALT
+	}
+
+	e := emulator.New()
+
+	if err := e.Load(program); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	e.SetWarnMisalignedPC(true)
+
+	if _, err := e.Step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	if _, err := e.Step(); err == nil {
+		t.Fatalf("expected an error for a misaligned jump")
+	}
+
+	var state emulator.State
+
+	e.State(&state)
+
+	if state.PC != 0x202 {
+		t.Fatalf("PC: got %04x, want 0202", state.PC)
+	}
+
+	// Without detection, the jump lands in the middle of an instruction.
+
+	e.SetWarnMisalignedPC(false)
+
+	if _, err := e.Step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	e.State(&state)
+
+	if state.PC != 0x205 {
+		t.Fatalf("PC: got %04x, want 0205", state.PC)
+	}
+}
+
+func TestWriteAheadTrap(t *testing.T) {
+	program := []uint8{
+		0xa2, 0x06, // LD I, 0x206
+		0xf1, 0x55, // LD [I], V1
+		0x00, 0x00, // HALT
+		0x00, 0x00, // HALT, written by LD [I], V1
+	}
+
+	e := emulator.New()
+
+	if err := e.Load(program); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	e.SetWriteAheadTrap(8)
+
+	if _, err := e.Step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	_, err := e.Step()
+	if err == nil {
+		t.Fatalf("expected an error for a write ahead of pc")
+	}
+
+	if want := "write ahead of pc: f155 at 0202 writes 0206-0207"; err.Error() != want {
+		t.Fatalf("error: got %q, want %q", err, want)
+	}
+
+	check(t, e).
+		index(0x206)
+
+	// Writes past the trap are allowed.
+
+	e.SetWriteAheadTrap(2)
+
+	if _, err := e.Step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+}
+
+func TestHaltReason(t *testing.T) {
+	e := run(t,
+		0x60, 0x01, // LD V0, 0x01
+		0x00, 0x00, // HALT
+	)
+
+	if !e.Halted() {
+		t.Fatal("the program should be halted")
+	}
+
+	if pc, ranOffEnd := e.HaltReason(); pc != 0x202 || ranOffEnd {
+		t.Fatalf("halt reason: got %04x %v, want 0202 false", pc, ranOffEnd)
+	}
+
+	// Without an explicit HALT, the program runs into the zeroed memory after it.
+
+	e = run(t,
+		0x60, 0x01, // LD V0, 0x01
+		0x61, 0x02, // LD V1, 0x02
+	)
+
+	if pc, ranOffEnd := e.HaltReason(); pc != 0x204 || !ranOffEnd {
+		t.Fatalf("halt reason: got %04x %v, want 0204 true", pc, ranOffEnd)
+	}
+
+	e.Reset()
+
+	if e.Halted() {
+		t.Fatal("reset should clear the halt")
+	}
+
+	if pc, ranOffEnd := e.HaltReason(); pc != 0 || ranOffEnd {
+		t.Fatalf("halt reason: got %04x %v, want 0000 false", pc, ranOffEnd)
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     uint8
+		exited bool
+	}{
+		{"halt", 0x00, false},
+		{"exit", 0xfd, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := run(t,
+				0x60, 0x01, // LD V0, 0x01
+				0x00, test.op, // HALT or EXIT
+				0x60, 0x02, // LD V0, 0x02
+			)
+
+			if !e.Halted() {
+				t.Fatal("the program should be halted")
+			}
+
+			if e.Exited() != test.exited {
+				t.Fatalf("exited: got %v, want %v", e.Exited(), test.exited)
+			}
+
+			if pc, _ := e.HaltReason(); pc != 0x202 {
+				t.Fatalf("halt address: got %04x, want 0202", pc)
+			}
+
+			check(t, e).register(0x0, 0x01)
+
+			// The program stays halted.
+
+			if ok, err := e.Step(); ok || err != nil {
+				t.Fatalf("step: got %v %v, want false nil", ok, err)
+			}
+
+			e.Reset()
+
+			if e.Exited() {
+				t.Fatal("reset should clear the exit")
+			}
+		})
+	}
 }
 
 func TestLoadIndex(t *testing.T) {
@@ -478,6 +836,38 @@ func TestLoadIndex(t *testing.T) {
 		index(0x2ff)
 }
 
+func TestLoadIndexLong(t *testing.T) {
+	e := run(t,
+		0x60, 0xab, // LD V0, 0xab
+		0xf0, 0x00, 0xf0, 0x00, // LD I, long 0xf000
+		0xf0, 0x55, // LD [I], V0
+		0x60, 0x00, // LD V0, 0x00
+		0xf0, 0x00, 0xf0, 0x00, // LD I, long 0xf000
+		0xf0, 0x65, // LD V0, [I]
+	)
+
+	// Loads and stores through I reach the memory beyond the first 4096 bytes.
+
+	check(t, e).
+		register(0x0, 0xab).
+		index(0xf001).
+		memory(0xf000, 0xab)
+}
+
+func TestSkipLoadIndexLong(t *testing.T) {
+	e := run(t,
+		0x30, 0x00, // SE V0, 0x00
+		0xf0, 0x00, 0x12, 0x34, // LD I, long 0x1234
+		0x61, 0x01, // LD V1, 0x01
+	)
+
+	// The whole instruction is skipped, rather than only its first two bytes.
+
+	check(t, e).
+		register(0x1, 0x01).
+		index(0x000)
+}
+
 func TestAddIndex(t *testing.T) {
 	e := run(t,
 		0x60, 0x01, // LD V0, 0x01
@@ -526,33 +916,249 @@ func TestLoadMemory(t *testing.T) {
 		memory(0x0301, 0x02)
 }
 
-func TestStoreBCD(t *testing.T) {
-	e := run(t,
-		0x60, 0xfe, // LD V0, 0xfe
-		0xa3, 0x00, // LD I, 0x300
-		0xf0, 0x33, // LD B, V0
-	)
+func TestAudioPattern(t *testing.T) {
+	pattern := emulator.AudioPattern{
+		0xff, 0x00, 0xff, 0x00, 0xf0, 0xf0, 0xf0, 0xf0,
+		0xaa, 0xaa, 0xaa, 0xaa, 0x81, 0x42, 0x24, 0x18,
+	}
 
-	check(t, e).
-		register(0x0, 0xfe).
-		index(0x300).
-		memory(0x300, 2).
-		memory(0x301, 5).
-		memory(0x302, 4)
+	e := run(t, append([]uint8{
+		0xa2, 0x0a, // LD I, 0x20a
+		0xf0, 0x02, // AUDIO
+		0x60, 0x70, // LD V0, 0x70
+		0xf0, 0x3a, // PITCH V0
+		0x00, 0x00, // HALT
+	}, pattern[:]...)...)
+
+	// A pitch of 48 steps above the default doubles the rate.
+
+	got, rate, ok := e.AudioPattern()
+
+	if !ok {
+		t.Fatal("pattern not loaded")
+	}
+
+	if got != pattern {
+		t.Fatalf("pattern: got %x, want %x", got, pattern)
+	}
+
+	if rate != 8000 {
+		t.Fatalf("rate: got %v, want 8000", rate)
+	}
+
+	e.Reset()
+
+	if _, rate, ok := e.AudioPattern(); ok || rate != 4000 {
+		t.Fatalf("after reset: got rate %v and loaded %v, want 4000 and false", rate, ok)
+	}
 }
 
-func TestDraw(t *testing.T) {
+func TestRPLFlags(t *testing.T) {
 	e := run(t,
-		0x60, 0x01, // LD V0, 0x01
-		0x61, 0x02, // LD V1, 0x02
-		0xa2, 0x0a, // LD I, 0x20a
-		0xd0, 0x12, // DRW V0, V1, 0x02
+		0xa2, 0x14, // LD I, 0x214
+		0xf8, 0x65, // LD V8, [I]
+		0xff, 0x75, // LD R, VF
+		0xa2, 0x1d, // LD I, 0x21d
+		0xf8, 0x65, // LD V8, [I]
+		0xff, 0x85, // LD VF, R
 		0x00, 0x00, // HALT
-		0x80, // Bitmap, *.......
-		0x01, // Bitmap, .......*
+		0x00, 0x00, // Padding
+		0x00, 0x00, // Padding
+		0x00, 0x00, // Padding
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, // Registers, followed by zeroed memory
 	)
 
-	check(t, e).
+	// Only V0 to V7 fit in the flags, so V8 is cleared and never restored.
+
+	c := check(t, e)
+
+	for x := range 8 {
+		c.register(x, uint8(x+1))
+	}
+
+	c.register(0x8, 0x00)
+
+	var state emulator.State
+
+	e.State(&state)
+
+	if want := (emulator.RPLFlags{1, 2, 3, 4, 5, 6, 7, 8}); state.Flags != want {
+		t.Fatalf("flags: got %v, want %v", state.Flags, want)
+	}
+
+	e.Reset()
+	e.State(&state)
+
+	if state.Flags != (emulator.RPLFlags{}) {
+		t.Fatalf("flags after reset: got %v", state.Flags)
+	}
+}
+
+// VF is a normal register for LD [I], Vx and LD Vx, [I], and is stored and
+// loaded like the others when X is F. No known interpreter excludes VF from the
+// range, so there is no quirk for it.
+
+func TestStoreMemoryIncludesVF(t *testing.T) {
+	e := run(t,
+		0x60, 0x01, // LD V0, 0x01
+		0x6f, 0xab, // LD VF, 0xab
+		0xa3, 0x00, // LD I, 0x300
+		0xff, 0x55, // LD [I], VF
+	)
+
+	check(t, e).
+		index(0x0310).
+		memory(0x0300, 0x01).
+		memory(0x030e, 0x00).
+		memory(0x030f, 0xab)
+}
+
+func TestLoadMemoryIncludesVF(t *testing.T) {
+	program := []uint8{
+		0xa2, 0x06, // LD I, 0x206
+		0xff, 0x65, // LD VF, [I]
+		0x00, 0x00, // HALT
+	}
+
+	for i := range 16 {
+		program = append(program, 0x10+uint8(i))
+	}
+
+	tests := []struct {
+		name              string
+		memoryIncrementsI bool
+		index             uint16
+	}{
+		{"mem-inc", true, 0x216},
+		{"mem-none", false, 0x206},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quirks := emulator.DefaultQuirks()
+			quirks.MemoryIncrementsI = tt.memoryIncrementsI
+
+			e := runQuirks(t, quirks, program...)
+
+			c := check(t, e).index(tt.index)
+
+			for x := range 16 {
+				c.register(x, 0x10+uint8(x))
+			}
+		})
+	}
+}
+
+func TestMemTracer(t *testing.T) {
+	e := emulator.New()
+
+	type access struct {
+		addr  uint16
+		write bool
+		value uint8
+	}
+
+	var accesses []access
+
+	e.SetMemTracer(func(addr uint16, write bool, value uint8) {
+		accesses = append(accesses, access{addr, write, value})
+	})
+
+	if err := e.Load([]uint8{
+		0x60, 0x01, // LD V0, 0x01
+		0x61, 0x02, // LD V1, 0x02
+		0xa3, 0x00, // LD I, 0x300
+		0xf1, 0x55, // LD [I], V1
+		0xa3, 0x00, // LD I, 0x300
+		0xf1, 0x65, // LD V1, [I]
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for {
+		ok, err := e.Step()
+		if err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		if !ok {
+			break
+		}
+	}
+
+	want := []access{
+		{0x300, true, 0x01},
+		{0x301, true, 0x02},
+		{0x300, false, 0x01},
+		{0x301, false, 0x02},
+	}
+
+	if len(accesses) != len(want) {
+		t.Fatalf("accesses: got %+v, want %+v", accesses, want)
+	}
+
+	for i := range want {
+		if accesses[i] != want[i] {
+			t.Fatalf("access %d: got %+v, want %+v", i, accesses[i], want[i])
+		}
+	}
+}
+
+func TestStoreBCD(t *testing.T) {
+	e := run(t,
+		0x60, 0xfe, // LD V0, 0xfe
+		0xa3, 0x00, // LD I, 0x300
+		0xf0, 0x33, // LD B, V0
+	)
+
+	check(t, e).
+		register(0x0, 0xfe).
+		index(0x300).
+		memory(0x300, 2).
+		memory(0x301, 5).
+		memory(0x302, 4)
+}
+
+func TestStoreBCDBoundaries(t *testing.T) {
+	tests := []struct {
+		value  uint8
+		digits [3]uint8
+	}{
+		{0, [3]uint8{0, 0, 0}},
+		{9, [3]uint8{0, 0, 9}},
+		{99, [3]uint8{0, 9, 9}},
+		{100, [3]uint8{1, 0, 0}},
+		{255, [3]uint8{2, 5, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.value), func(t *testing.T) {
+			e := run(t,
+				0x60, tt.value, // LD V0, value
+				0xa3, 0x00, // LD I, 0x300
+				0xf0, 0x33, // LD B, V0
+			)
+
+			check(t, e).
+				index(0x300).
+				memory(0x300, tt.digits[0]).
+				memory(0x301, tt.digits[1]).
+				memory(0x302, tt.digits[2])
+		})
+	}
+}
+
+func TestDraw(t *testing.T) {
+	e := run(t,
+		0x60, 0x01, // LD V0, 0x01
+		0x61, 0x02, // LD V1, 0x02
+		0xa2, 0x0a, // LD I, 0x20a
+		0xd0, 0x12, // DRW V0, V1, 0x02
+		0x00, 0x00, // HALT
+		0x80, // Bitmap, *.......
+		0x01, // Bitmap, .......*
+	)
+
+	check(t, e).
 		register(0x0, 0x01).
 		register(0x01, 0x02).
 		register(0x0f, 0x00).
@@ -582,254 +1188,1637 @@ func TestDrawCollision(t *testing.T) {
 		display(8, 3, true)
 }
 
-func TestClearDisplay(t *testing.T) {
-	e := run(t,
-		0x60, 0x01, // LD V0, 0x01
-		0x61, 0x02, // LD V1, 0x02
-		0xa2, 0x0a, // LD I, 0x20c
-		0xd0, 0x12, // DRW V0, V1, 0x01
-		0x00, 0xe0, // CLS
+func TestSetDisplay(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0x60, 0x08, // LD V0, 0x08
+		0x61, 0x04, // LD V1, 0x04
+		0xa2, 0x0a, // LD I, 0x20a
+		0xd0, 0x11, // DRW V0, V1, 0x01
 		0x00, 0x00, // HALT
-		0x80, // Bitmap, *.......
-	)
+		0x01, // Bitmap, .......*
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// The pixel at (15, 4) is only set by the display, and collides with the
+	// sprite.
+
+	var display emulator.Display
+
+	display[4][15] = 1
+	display[4][16] = 1
+
+	e.SetDisplay(&display)
+
+	for {
+		ok, err := e.Step()
+		if err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		if !ok {
+			break
+		}
+	}
 
 	check(t, e).
-		register(0x0, 0x01).
-		register(0x01, 0x02).
-		register(0x0f, 0x00).
-		index(0x20a).
-		display(1, 2, false)
+		register(0xf, 0x01).
+		display(15, 4, false).
+		display(16, 4, true)
+
+	if !e.UndoLastDraw() {
+		t.Fatal("the sprite drawn after SetDisplay should be reverted")
+	}
+
+	check(t, e).
+		display(15, 4, true)
+
+	e.SetDisplay(&display)
+
+	if e.UndoLastDraw() {
+		t.Fatal("SetDisplay should discard the last sprite drawn")
+	}
 }
 
-func TestCharacterAddress(t *testing.T) {
+func TestSetDisplayPlanes(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0xf2, 0x01, // PLANE 2
+		0x60, 0x08, // LD V0, 0x08
+		0x61, 0x04, // LD V1, 0x04
+		0xa2, 0x0c, // LD I, 0x20c
+		0xd0, 0x11, // DRW V0, V1, 0x01
+		0x00, 0x00, // HALT
+		0x01, // Bitmap, .......*
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// The pixel at (15, 4) is only on in the second plane, since the bits other
+	// than the planes are ignored, and collides with the sprite drawn in the
+	// second plane. The pixel at (16, 4) is only on in the first plane.
+
+	var display emulator.Display
+
+	display[4][15] = 0xfe
+	display[4][16] = 1
+
+	e.SetDisplay(&display)
+
+	for {
+		ok, err := e.Step()
+		if err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		if !ok {
+			break
+		}
+	}
+
+	check(t, e).register(0xf, 0x01)
+
+	var state emulator.State
+
+	e.State(&state)
+
+	if got := state.Display[4][15]; got != 0 {
+		t.Fatalf("display[15,4]: got %d, want 0", got)
+	}
+
+	if got := state.Display[4][16]; got != 1 {
+		t.Fatalf("display[16,4]: got %d, want 1", got)
+	}
+}
+
+func TestDrawAlwaysClearsVF(t *testing.T) {
+	// A sprite of height zero doesn't draw any row, but VF is still reset as if
+	// a sprite without collisions was drawn.
+
 	e := run(t,
-		0x60, 0x0f, // LD V0, 0x0f
-		0xf0, 0x29, // LD F, V0
-		0xd1, 0x15, // DRW V1, V1, 0x05
+		0x6f, 0x01, // LD VF, 0x01
+		0xd0, 0x00, // DRW V0, V0, 0x00
 	)
 
 	check(t, e).
-		register(0x0, 0x0f).
-		register(0xf, 0).
+		register(0xf, 0x00).
+		display(0, 0, false)
+}
+
+func TestDrawEmptyRowsDontCollide(t *testing.T) {
+	// Rows without bits set don't change any pixel, so they can't collide with
+	// the pixels that are already on. The second sprite is drawn both where the
+	// whole row fits in the display, and across the right edge, where pixels
+	// are drawn one at a time.
+
+	tests := []struct {
+		name string
+		x    uint8
+	}{
+		{"inside", 0x00},
+		{"right edge", 0x3c},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := run(t,
+				0x60, tt.x, // LD V0, x
+				0xa2, 0x0e, // LD I, 0x20e
+				0xd0, 0x12, // DRW V0, V1, 0x02
+				0xa2, 0x10, // LD I, 0x210
+				0x6f, 0x01, // LD VF, 0x01
+				0xd0, 0x12, // DRW V0, V1, 0x02
+				0x00, 0x00, // HALT
+				0xff, // Bitmap, ########
+				0xff, // Bitmap, ########
+				0x00, // Bitmap, ........
+				0x00, // Bitmap, ........
+			)
+
+			check(t, e).
+				register(0xf, 0x00).
+				display(int(tt.x), 0, true).
+				display(int(tt.x)+3, 1, true)
+		})
+	}
+}
+
+func TestDrawHeightZero(t *testing.T) {
+	program := []uint8{
+		0x6f, 0x01, // LD VF, 0x01
+		0xa2, 0x08, // LD I, 0x208
+		0xd0, 0x00, // DRW V0, V0, 0x00
+		0x00, 0x00, // HALT
+		0x80, 0x01, // Bitmap, *..............*
+	}
+
+	for range 14 {
+		program = append(program, 0x00, 0x00) // Bitmap, empty row
+	}
+
+	program = append(program, 0xff, 0xff) // Bitmap, ****************
+
+	// The original interpreter draws nothing, but still resets VF.
+
+	e := run(t, program...)
+
+	check(t, e).
+		register(0xf, 0x00).
+		display(0, 0, false).
+		display(0, 15, false)
+
+	// SUPER-CHIP draws a 16×16 sprite.
+
+	quirks := emulator.DefaultQuirks()
+	quirks.LargeSprites = true
+
+	e = runQuirks(t, quirks, program...)
+
+	check(t, e).
+		register(0xf, 0x00).
 		display(0, 0, true).
-		display(1, 0, true).
-		display(2, 0, true).
-		display(3, 0, true).
-		display(0, 1, true).
-		display(0, 2, true).
-		display(1, 2, true).
-		display(2, 2, true).
-		display(3, 2, true).
-		display(0, 3, true).
-		display(0, 4, true)
+		display(1, 0, false).
+		display(15, 0, true).
+		display(16, 0, false).
+		display(0, 1, false).
+		display(8, 15, true).
+		display(15, 15, true).
+		display(0, 16, false)
 }
 
-func TestSoundTimer(t *testing.T) {
-	e := emulator.New()
+func TestDrawLargeSpriteHighResolution(t *testing.T) {
+	program := []uint8{
+		0x00, 0xff, // HIGH
+		0x60, 0x70, // LD V0, 0x70
+		0x61, 0x30, // LD V1, 0x30
+		0xa2, 0x14, // LD I, 0x214
+		0xd0, 0x10, // DRW V0, V1, 0x00
+		0x82, 0xf0, // LD V2, VF
+		0x70, 0x08, // ADD V0, 0x08
+		0xd0, 0x10, // DRW V0, V1, 0x00
+		0x00, 0x00, // HALT
+		0x00, 0x00, // Padding
+	}
 
-	var sound bool
+	// The sprite is the outline of a 16×16 square.
 
-	e.SetSound(func() {
-		sound = true
-	})
+	program = append(program, 0xff, 0xff)
+
+	for range 14 {
+		program = append(program, 0x80, 0x01)
+	}
+
+	program = append(program, 0xff, 0xff)
+
+	// The first sprite fills the bottom-right corner of the display. The
+	// second one overlaps the right half of the first, and is clipped at the
+	// right edge.
+
+	e := runQuirks(t, emulator.VariantSCHIP.Quirks(), program...)
+
+	check(t, e).
+		register(0x2, 0x00).
+		register(0xf, 0x01).
+		display(112, 48, true).
+		display(120, 48, false).
+		display(127, 48, false).
+		display(112, 55, true).
+		display(120, 55, true).
+		display(127, 55, true).
+		display(113, 63, true).
+		display(121, 63, false).
+		display(111, 55, false)
+}
+
+func TestDrawCustomResolution(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.SetResolution(96, 48); err != nil {
+		t.Fatalf("set resolution: %v", err)
+	}
 
 	if err := e.Load([]uint8{
-		0x60, 0x0f, // LD V0, 0x0f
-		0xf0, 0x18, // LD ST, V0
-		0xf0, 0x15, // LD DT, V0
-		0xf1, 0x07, // LD V1, DT
-		0x31, 0x00, // SE V1, 0x00
-		0x12, 0x06, // JP 0x206
+		0x60, 0x5f, // LD V0, 0x5f
+		0x61, 0x2e, // LD V1, 0x2e
+		0xa2, 0x0a, // LD I, 0x20a
+		0xd0, 0x12, // DRW V0, V1, 0x02
+		0x00, 0x00, // HALT
+		0xc0, // Bitmap, **......
+		0xc0, // Bitmap, **......
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for {
+		ok, err := e.Step()
+		if err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		if !ok {
+			break
+		}
+	}
+
+	if w, h := e.Resolution(); w != 96 || h != 48 {
+		t.Fatalf("resolution: got %dx%d, want 96x48", w, h)
+	}
+
+	check(t, e).
+		display(95, 46, true).
+		display(95, 47, true).
+		display(96, 46, false).
+		display(0, 46, false).
+		display(95, 48, false)
+}
+
+func TestSwitchResolution(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0x00, 0xff, // HIGH
+		0x60, 0x7f, // LD V0, 0x7f
+		0x61, 0x3f, // LD V1, 0x3f
+		0xa2, 0x0e, // LD I, 0x20e
+		0xd0, 0x11, // DRW V0, V1, 0x01
+		0x00, 0xfe, // LOW
+		0x00, 0x00, // HALT
+		0x80, // Bitmap, *.......
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// Step until the sprite is drawn, in the bottom-right corner of the high
+	// resolution display.
+
+	for range 5 {
+		if _, err := e.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+	}
+
+	if w, h := e.Resolution(); w != emulator.MaxDisplayWidth || h != emulator.MaxDisplayHeight {
+		t.Fatalf("high resolution: got %dx%d", w, h)
+	}
+
+	check(t, e).display(127, 63, true)
+
+	// Switching back to the low resolution clears the display.
+
+	if _, err := e.Step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	var state emulator.State
+	e.State(&state)
+
+	if state.Width != emulator.DisplayWidth || state.Height != emulator.DisplayHeight {
+		t.Fatalf("low resolution: got %dx%d", state.Width, state.Height)
+	}
+
+	check(t, e).
+		display(127, 63, false).
+		register(0xf, 0x00)
+}
+
+func TestDrawPlanes(t *testing.T) {
+	e := run(t,
+		0xa2, 0x12, // LD I, 0x212
+		0xd0, 0x01, // DRW V0, V0, 0x01
+		0xf2, 0x01, // PLANE 2
+		0xa2, 0x13, // LD I, 0x213
+		0xd0, 0x01, // DRW V0, V0, 0x01
+		0xf3, 0x01, // PLANE 3
+		0xa2, 0x14, // LD I, 0x214
+		0xd0, 0x01, // DRW V0, V0, 0x01
+		0x00, 0x00, // HALT
+		0xf0, // Bitmap, ****....
+		0x3c, // Bitmap, ..****..
+		0x03, // Bitmap, ......**
+		0x0c, // Bitmap, ....**..
+	)
+
+	var state emulator.State
+
+	e.State(&state)
+
+	// The first sprite is drawn in the first plane, and the second one in the
+	// second plane only, which leaves the first plane untouched. The last draw
+	// takes a row for each plane, and collides in the second one.
+
+	want := []uint8{0x1, 0x1, 0x3, 0x3, 0x0, 0x0, 0x1, 0x1, 0x0}
+
+	for x, p := range want {
+		if got := state.Display[0][x]; got != p {
+			t.Fatalf("display[%d,0]: got planes %d, want %d", x, got, p)
+		}
+	}
+
+	check(t, e).register(0xf, 0x01)
+
+	// Reverting the last draw restores both planes.
+
+	if !e.UndoLastDraw() {
+		t.Fatal("no draw to undo")
+	}
+
+	e.State(&state)
+
+	want = []uint8{0x1, 0x1, 0x3, 0x3, 0x2, 0x2, 0x0, 0x0}
+
+	for x, p := range want {
+		if got := state.Display[0][x]; got != p {
+			t.Fatalf("display[%d,0] after undo: got planes %d, want %d", x, got, p)
+		}
+	}
+}
+
+func TestClearPlane(t *testing.T) {
+	e := run(t,
+		0xf3, 0x01, // PLANE 3
+		0xa2, 0x0c, // LD I, 0x20c
+		0xd0, 0x01, // DRW V0, V0, 0x01
+		0xf2, 0x01, // PLANE 2
+		0x00, 0xe0, // CLS
+		0x00, 0x00, // HALT
+		0xc0, // Bitmap, **......
+		0x60, // Bitmap, .**.....
+	)
+
+	var state emulator.State
+
+	e.State(&state)
+
+	// Only the second plane is cleared.
+
+	want := []uint8{0x1, 0x1, 0x0}
+
+	for x, p := range want {
+		if got := state.Display[0][x]; got != p {
+			t.Fatalf("display[%d,0]: got planes %d, want %d", x, got, p)
+		}
+	}
+}
+
+func TestScroll(t *testing.T) {
+	tests := []struct {
+		name       string
+		resolution uint8
+		scroll     uint16
+		want       string
+	}{
+		{
+			name:       "down high",
+			resolution: 0xff,
+			scroll:     0x00c3,
+			want: `
+				......
+				......
+				......
+				......
+				......
+				....##
+				....#.
+			`,
+		},
+		{
+			name:       "up high",
+			resolution: 0xff,
+			scroll:     0x00d1,
+			want: `
+				......
+				....##
+				....#.
+			`,
+		},
+		{
+			name:       "right high",
+			resolution: 0xff,
+			scroll:     emulator.OpSCR,
+			want: `
+				..........
+				..........
+				........##
+				........#.
+			`,
+		},
+		{
+			name:       "left high",
+			resolution: 0xff,
+			scroll:     emulator.OpSCL,
+			want: `
+				..
+				..
+				##
+				#.
+			`,
+		},
+		{
+			name:       "down low",
+			resolution: 0xfe,
+			scroll:     0x00c3,
+			want: `
+				......
+				......
+				......
+				....##
+				....#.
+			`,
+		},
+		{
+			name:       "up low",
+			resolution: 0xfe,
+			scroll:     0x00d2,
+			want: `
+				......
+				....##
+				....#.
+			`,
+		},
+		{
+			name:       "right low",
+			resolution: 0xfe,
+			scroll:     emulator.OpSCR,
+			want: `
+				........
+				........
+				......##
+				......#.
+			`,
+		},
+		{
+			name:       "left low",
+			resolution: 0xfe,
+			scroll:     emulator.OpSCL,
+			want: `
+				....
+				....
+				..##
+				..#.
+			`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := run(t,
+				0x00, test.resolution, // HIGH or LOW
+				0x60, 0x04, // LD V0, 0x04
+				0x61, 0x02, // LD V1, 0x02
+				0xa2, 0x0e, // LD I, 0x20e
+				0xd0, 0x12, // DRW V0, V1, 0x02
+				uint8(test.scroll>>8), uint8(test.scroll), // SCD, SCU, SCR, or SCL
+				0x00, 0x00, // HALT
+				0xc0, // Bitmap, **......
+				0x80, // Bitmap, *.......
+			)
+
+			check(t, e).displayMatches(test.want)
+		})
+	}
+}
+
+func TestScrollOffDisplay(t *testing.T) {
+	e := run(t,
+		0x00, 0xff, // HIGH
+		0x60, 0x7e, // LD V0, 0x7e
+		0x61, 0x3e, // LD V1, 0x3e
+		0xa2, 0x10, // LD I, 0x210
+		0xd0, 0x12, // DRW V0, V1, 0x02
+		0x00, 0xfb, // SCR
+		0x00, 0xcf, // SCD 0xf
+		0x00, 0x00, // HALT
+		0xc0, // Bitmap, **......
+		0xc0, // Bitmap, **......
+	)
+
+	// The sprite in the bottom-right corner is scrolled out of the display, and
+	// the vacated pixels are turned off.
+
+	check(t, e).displayMatches("")
+}
+
+func TestSetInvalidResolution(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.SetResolution(emulator.MaxDisplayWidth+1, emulator.DisplayHeight); err == nil {
+		t.Fatal("expected error for oversized resolution")
+	}
+
+	if err := e.SetResolution(0, emulator.DisplayHeight); err == nil {
+		t.Fatal("expected error for empty resolution")
+	}
+}
+
+func TestDisplayInto(t *testing.T) {
+	e := run(t,
+		0x61, 0x0c, // LD V1, 0x0c
+		0xd0, 0x05, // DRW V0, V0, 0x05
+		0xd1, 0x15, // DRW V1, V1, 0x05
+	)
+
+	buf := make([]byte, emulator.DisplayWidth*emulator.DisplayHeight/8)
+
+	// Fill the buffer to check that DisplayInto clears it.
+
+	for i := range buf {
+		buf[i] = 0xff
+	}
+
+	n, err := e.DisplayInto(buf)
+	if err != nil {
+		t.Fatalf("display: %v", err)
+	}
+	if n != len(buf) {
+		t.Fatalf("written: got %d, want %d", n, len(buf))
+	}
+
+	want := make([]byte, len(buf))
+
+	// The sprite of the digit 0 is drawn at (0, 0) and at (12, 12). Every row of
+	// the display is 8 bytes long.
+
+	for i, row := range []byte{0xf0, 0x90, 0x90, 0x90, 0xf0} {
+		want[i*8] = row
+		want[(12+i)*8+1] = row >> 4
+	}
+
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("packed display:\ngot  %x\nwant %x", buf, want)
+	}
+
+	if _, err := e.DisplayInto(buf[:len(buf)-1]); err == nil {
+		t.Fatal("expected error for short buffer")
+	}
+}
+
+func TestCollisionCount(t *testing.T) {
+	e := run(t,
+		0xd0, 0x05, // DRW V0, V0, 0x05
+		0xd0, 0x05, // DRW V0, V0, 0x05
+		0xd0, 0x05, // DRW V0, V0, 0x05
+		0xd0, 0x05, // DRW V0, V0, 0x05
+		0x61, 0x10, // LD V1, 0x10
+		0xd1, 0x15, // DRW V1, V1, 0x05
+	)
+
+	// Drawing the same sprite twice erases it, so only the second and the fourth
+	// draws collide. The last sprite is drawn on an empty area.
+
+	if got := e.CollisionCount(); got != 2 {
+		t.Fatalf("collisions: got %d, want 2", got)
+	}
+
+	e.ResetCollisionCount()
+
+	if got := e.CollisionCount(); got != 0 {
+		t.Fatalf("collisions after reset: got %d, want 0", got)
+	}
+}
+
+func TestDrawsThisFrame(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0xa2, 0x08, // LD I, 0x208
+		0xd0, 0x01, // DRW V0, V0, 0x01
+		0xd0, 0x01, // DRW V0, V0, 0x01
+		0xd0, 0x01, // DRW V0, V0, 0x01
+		0x80, // Bitmap, *.......
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for range 3 {
+		if _, err := e.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+	}
+
+	var state emulator.State
+
+	e.State(&state)
+
+	if state.DrawsThisFrame != 2 {
+		t.Fatalf("draws: got %d, want 2", state.DrawsThisFrame)
+	}
+
+	e.Clock()
+
+	if _, err := e.Step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	e.State(&state)
+
+	if state.DrawsThisFrame != 1 {
+		t.Fatalf("draws after clock: got %d, want 1", state.DrawsThisFrame)
+	}
+}
+
+func TestMaxDrawsPerFrame(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0xa2, 0x06, // LD I, 0x206
+		0xd0, 0x01, // DRW V0, V0, 0x01
+		0x12, 0x02, // JP 0x202
+		0x80, // Bitmap, *.......
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	var warnings []uint64
+
+	e.SetMaxDrawsPerFrame(3)
+	e.SetDrawLimitWarning(func(frame uint64) {
+		warnings = append(warnings, frame)
+	})
+
+	for frame := range uint64(2) {
+		for range 20 {
+			if _, err := e.Step(); err != nil {
+				t.Fatalf("step: %v", err)
+			}
+		}
+
+		var state emulator.State
+
+		e.State(&state)
+
+		if state.DrawsThisFrame != 3 {
+			t.Fatalf("frame %d: draws: got %d, want 3", frame, state.DrawsThisFrame)
+		}
+
+		// The warning is reported once per frame, however many draws are
+		// skipped.
+
+		if len(warnings) != int(frame)+1 || warnings[frame] != frame {
+			t.Fatalf("frame %d: warnings: got %v", frame, warnings)
+		}
+
+		e.Clock()
+	}
+
+	// Six sprites have been drawn in the same spot, and the skipped draws left
+	// the display and VF alone.
+
+	check(t, e).display(0, 0, false).register(0xf, 1)
+}
+
+func TestDisplayWaitOneDrawPerFrame(t *testing.T) {
+	e := emulator.New()
+
+	e.SetQuirks(emulator.VariantVIP.Quirks())
+
+	if err := e.Load([]uint8{
+		0xa2, 0x06, // LD I, 0x206
+		0xd0, 0x01, // DRW V0, V0, 0x01
+		0x12, 0x02, // JP 0x202
+		0x80, // Bitmap, *.......
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	var state emulator.State
+
+	for frame := range uint64(5) {
+		for range 20 {
+			if _, err := e.Step(); err != nil {
+				t.Fatalf("step: %v", err)
+			}
+		}
+
+		e.State(&state)
+
+		if state.FrameCount != frame {
+			t.Fatalf("frame count: got %d, want %d", state.FrameCount, frame)
+		}
+		if state.DrawsThisFrame != 1 {
+			t.Fatalf("frame %d: got %d draws, want 1", frame, state.DrawsThisFrame)
+		}
+
+		e.Clock()
+	}
+}
+
+func TestUndoLastDraw(t *testing.T) {
+	e := run(t,
+		0x60, 0x01, // LD V0, 0x01
+		0x61, 0x02, // LD V1, 0x02
+		0x6f, 0x07, // LD VF, 0x07
+		0xa2, 0x0c, // LD I, 0x20c
+		0xd0, 0x12, // DRW V0, V1, 0x02
+		0x00, 0x00, // HALT
+		0x80, // Bitmap, *.......
+		0x01, // Bitmap, .......*
+	)
+
+	check(t, e).
+		register(0xf, 0x00).
+		display(1, 2, true).
+		display(8, 3, true)
+
+	if !e.UndoLastDraw() {
+		t.Fatal("expected a draw to undo")
+	}
+
+	check(t, e).
+		register(0xf, 0x07).
+		display(1, 2, false).
+		display(8, 3, false)
+
+	if e.UndoLastDraw() {
+		t.Fatal("expected no draw to undo")
+	}
+}
+
+func TestClearDisplay(t *testing.T) {
+	e := run(t,
+		0x60, 0x01, // LD V0, 0x01
+		0x61, 0x02, // LD V1, 0x02
+		0xa2, 0x0a, // LD I, 0x20c
+		0xd0, 0x12, // DRW V0, V1, 0x01
+		0x00, 0xe0, // CLS
+		0x00, 0x00, // HALT
+		0x80, // Bitmap, *.......
+	)
+
+	check(t, e).
+		register(0x0, 0x01).
+		register(0x01, 0x02).
+		register(0x0f, 0x00).
+		index(0x20a).
+		display(1, 2, false)
+}
+
+func TestClearDisplayIsImmediate(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.SetResolution(emulator.MaxDisplayWidth, emulator.MaxDisplayHeight); err != nil {
+		t.Fatalf("set resolution: %v", err)
+	}
+
+	if err := e.Load([]uint8{
+		0x60, 0x7c, // LD V0, 0x7c
+		0x61, 0x3d, // LD V1, 0x3d
+		0xd0, 0x15, // DRW V0, V1, 0x05
+		0x70, 0x02, // ADD V0, 0x02
+		0xd0, 0x15, // DRW V0, V1, 0x05
+		0x00, 0xe0, // CLS
+		0x00, 0x00, // HALT
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for {
+		ok, err := e.Step()
+		if err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		if !ok {
+			break
+		}
+	}
+
+	var state emulator.State
+
+	e.State(&state)
+
+	// The sprites are clipped at the corner of the display, and the second one
+	// collides with the first one. CLS clears every pixel, and leaves VF as set
+	// by the last collision.
+
+	if state.Display != (emulator.Display{}) {
+		t.Fatal("display not cleared")
+	}
+	if state.V[0xf] != 1 {
+		t.Fatalf("vf = %d, want 1", state.V[0xf])
+	}
+	if state.PC != 0x20c {
+		t.Fatalf("pc = %04x, want 020c", state.PC)
+	}
+}
+
+func TestCharacterAddress(t *testing.T) {
+	e := run(t,
+		0x60, 0x0f, // LD V0, 0x0f
+		0xf0, 0x29, // LD F, V0
+		0xd1, 0x15, // DRW V1, V1, 0x05
+	)
+
+	check(t, e).
+		register(0x0, 0x0f).
+		register(0xf, 0).
+		displayMatches(`
+			####
+			#...
+			####
+			#...
+			#...
+		`)
+}
+
+func TestLargeCharacterAddress(t *testing.T) {
+	e := run(t,
+		0x60, 0x09, // LD V0, 0x09
+		0xf0, 0x30, // LD HF, V0
+		0xd1, 0x1a, // DRW V1, V1, 0x0a
+	)
+
+	// The large font follows the small one, which is still in place.
+
+	check(t, e).
+		register(0xf, 0).
+		index(0x50+9*emulator.LargeFontSize).
+		memory(0x00, 0xf0).
+		displayMatches(`
+			########
+			########
+			##....##
+			##....##
+			########
+			########
+			......##
+			......##
+			########
+			########
+		`)
+}
+
+func TestLargeCharacterAddressHighDigit(t *testing.T) {
+	e := run(t,
+		0x60, 0x1f, // LD V0, 0x1f
+		0xf0, 0x30, // LD HF, V0
+	)
+
+	// Only the low nibble of the register selects the digit.
+
+	check(t, e).index(0x50 + 0xf*emulator.LargeFontSize)
+}
+
+func TestSpriteAt(t *testing.T) {
+	e := run(t,
+		0x60, 0x0f, // LD V0, 0x0f
+		0xf0, 0x29, // LD F, V0
+	)
+
+	sprite, err := e.SpriteAt(5)
+	if err != nil {
+		t.Fatalf("sprite: %v", err)
+	}
+
+	// The glyph of F, as stored in the font.
+
+	if want := []byte{0xf0, 0x80, 0xf0, 0x80, 0x80}; !bytes.Equal(sprite, want) {
+		t.Fatalf("sprite: got % x, want % x", sprite, want)
+	}
+
+	// The sprite is a copy, so changing it leaves the memory alone.
+
+	sprite[0] = 0
+
+	if again, _ := e.SpriteAt(1); again[0] != 0xf0 {
+		t.Fatalf("sprite after change: got %02x, want f0", again[0])
+	}
+}
+
+func TestSpriteAtOutOfBounds(t *testing.T) {
+	e := run(t,
+		0xf0, 0x00, 0xff, 0xfe, // LD I, long 0xfffe
+	)
+
+	if _, err := e.SpriteAt(2); err != nil {
+		t.Fatalf("sprite at the end of memory: %v", err)
+	}
+
+	if _, err := e.SpriteAt(3); err == nil {
+		t.Fatalf("no error for a sprite past the end of memory")
+	}
+
+	if _, err := e.SpriteAt(-1); err == nil {
+		t.Fatalf("no error for a negative height")
+	}
+}
+
+func TestSoundTimer(t *testing.T) {
+	e := emulator.New()
+
+	var sound bool
+
+	e.SetSound(func() {
+		sound = true
+	})
+
+	if err := e.Load([]uint8{
+		0x60, 0x0f, // LD V0, 0x0f
+		0xf0, 0x18, // LD ST, V0
+		0xf0, 0x15, // LD DT, V0
+		0xf1, 0x07, // LD V1, DT
+		0x31, 0x00, // SE V1, 0x00
+		0x12, 0x06, // JP 0x206
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	var steps int
+
+	for {
+		ok, err := e.Step()
+		if err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		if !ok {
+			break
+		}
+		steps++
+		if steps%8 == 0 {
+			e.Clock()
+		}
+	}
+
+	if !sound {
+		t.Fatalf("sound timer not triggered")
+	}
+}
+
+func TestDelayTimer(t *testing.T) {
+	e := run(t,
+		0x60, 0x0f, // LD V0, 0x0f
+		0xf0, 0x15, // LD DT, V0
+		0xf1, 0x07, // LD V1, DT
+		0x31, 0x00, // SE V1, 0x00
+		0x12, 0x04, // JP 0x204
+	)
+
+	check(t, e).
+		register(0x0, 0x0f).
+		register(0x1, 0x00).
+		delayTimer(0x00)
+}
+
+func TestTimersDisabled(t *testing.T) {
+	e := run(t,
+		0x60, 0x0f, // LD V0, 0x0f
+		0xf0, 0x15, // LD DT, V0
+	)
+
+	e.SetTimersEnabled(false)
+	e.Clock()
+
+	check(t, e).
+		delayTimer(0x0f)
+
+	e.SetTimersEnabled(true)
+	e.Clock()
+
+	check(t, e).
+		delayTimer(0x0e)
+}
+
+func TestCPUDisabled(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0x60, 0x0f, // LD V0, 0x0f
+		0xf0, 0x15, // LD DT, V0
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	e.SetCPUEnabled(false)
+
+	ok, err := e.Step()
+	if err != nil {
+		t.Fatalf("step: %v", err)
+	}
+	if !ok {
+		t.Fatal("should continue")
+	}
+
+	check(t, e).
+		register(0x0, 0x00)
+
+	e.SetCPUEnabled(true)
+
+	if _, err := e.Step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	check(t, e).
+		register(0x0, 0x0f)
+}
+
+func TestSkipOnKeyDown(t *testing.T) {
+	e := emulator.New()
+
+	e.KeyDown(0xf)
+
+	if err := e.Load([]uint8{
+		0x60, 0x0f, // LD V0, 0x0f
+		0xe0, 0x9e, // SKP V0
+		0x61, 0x01, // LD V1, 0x01
+		0x60, 0x0e, // LD V0, 0x0e
+		0xe0, 0x9e, // SKP V0
+		0x62, 0x01, // LD V2, 0x02
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for {
+		ok, err := e.Step()
+		if err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		if !ok {
+			break
+		}
+	}
+
+	check(t, e).
+		register(0x1, 0x00).
+		register(0x2, 0x01)
+}
+
+func TestSkipOnKeyNotDown(t *testing.T) {
+	e := emulator.New()
+
+	e.KeyDown(0xf)
+
+	if err := e.Load([]uint8{
+		0x60, 0x0f, // LD V0, 0x0f
+		0xe0, 0xa1, // SKPN V0
+		0x61, 0x01, // LD V1, 0x01
+		0x60, 0x0e, // LD V0, 0x0e
+		0xe0, 0xa1, // SKPN V0
+		0x62, 0x01, // LD V2, 0x02
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for {
+		ok, err := e.Step()
+		if err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		if !ok {
+			break
+		}
+	}
+
+	check(t, e).
+		register(0x1, 0x01).
+		register(0x2, 0x00)
+}
+
+func TestSkipWithoutKeyDown(t *testing.T) {
+	e := run(t,
+		0x60, 0x0f, // LD V0, 0x0f
+		0xe0, 0x9e, // SKP V0
+		0x61, 0x01, // LD V1, 0x01
+		0xe0, 0xa1, // SKPN V0
+		0x62, 0x01, // LD V2, 0x01
+	)
+
+	check(t, e).
+		register(0x1, 0x01).
+		register(0x2, 0x00)
+}
+
+func TestWaitKeyPress(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0xf0, 0x0a, // LD V0, K
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	ok, err := e.Step()
+	if err != nil {
+		t.Fatalf("step: %v", err)
+	}
+	if !ok {
+		t.Fatal("should continue")
+	}
+
+	check(t, e).
+		register(0x0, 0x00)
+
+	e.KeyDown(0xf)
+	e.KeyUp(0xf)
+	e.KeyDown(0x1)
+	e.KeyUp(0x1)
+
+	check(t, e).
+		register(0x0, 0x0f)
+
+	ok, err = e.Step()
+	if err != nil {
+		t.Fatalf("step: %v", err)
+	}
+	if ok {
+		t.Fatal("should stop")
+	}
+}
+
+func TestQueueKey(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0xf0, 0x0a, // LD V0, K
+		0x61, 0x01, // LD V1, 0x01
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// The key is pressed and released before the program starts waiting for it.
+	// The press is applied by the first step, which starts waiting, and the
+	// release by the second one, which completes the wait.
+
+	e.QueueKey(true, 0x7)
+	e.QueueKey(false, 0x7)
+
+	for range 2 {
+		if _, err := e.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+	}
+
+	check(t, e).
+		register(0x0, 0x07).
+		register(0x1, 0x01)
+}
+
+func TestAnyKeyPressed(t *testing.T) {
+	e := emulator.New()
+
+	if e.AnyKeyPressed() {
+		t.Fatal("no key should be pressed")
+	}
+
+	e.KeyDown(0xa)
+
+	if !e.AnyKeyPressed() {
+		t.Fatal("a key should be pressed")
+	}
+
+	e.KeyUp(0xa)
+
+	if e.AnyKeyPressed() {
+		t.Fatal("no key should be pressed after release")
+	}
+}
+
+func TestRandom(t *testing.T) {
+	e := emulator.New()
+
+	e.SetRNG(func() uint32 {
+		return 0x88
+	})
+
+	if err := e.Load([]uint8{
+		0xc0, 0x0f, // RND V0, 0xff
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for {
+		ok, err := e.Step()
+		if err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		if !ok {
+			break
+		}
+	}
+
+	check(t, e).
+		register(0x0, 0x08)
+}
+
+func TestReset(t *testing.T) {
+	e := run(t,
+		0x60, 0x01, // LD V0, 0x01
+		0xa3, 0x00, // LD I, 0x300
+		0xf0, 0x55, // LD [I], V0
+	)
+
+	e.Reset()
+
+	check(t, e).
+		register(0x0, 0x00).
+		index(0x000).
+		memory(0x200, 0x60).
+		memory(0x201, 0x01).
+		memory(0x300, 0x00)
+
+	var state emulator.State
+
+	e.State(&state)
+
+	if state.PC != emulator.ProgramStart {
+		t.Fatalf("program counter not reset")
+	}
+}
+
+func TestRegisterStats(t *testing.T) {
+	e := run(t,
+		0x60, 0x01, // LD V0, 0x01
+		0x61, 0x02, // LD V1, 0x02
+		0x80, 0x14, // ADD V0, V1
+		0x31, 0x02, // SE V1, 0x02
+		0x00, 0x00, // Skipped
+	)
+
+	stats := e.RegisterStats()
+
+	want := map[int]emulator.RegisterStat{
+		0x0: {Reads: 1, Writes: 2},
+		0x1: {Reads: 2, Writes: 1},
+		0x2: {Reads: 0, Writes: 0},
+		0xf: {Reads: 0, Writes: 1},
+	}
+
+	for r, w := range want {
+		if stats[r] != w {
+			t.Errorf("register %X: got %+v, want %+v", r, stats[r], w)
+		}
+	}
+
+	e.Reset()
+
+	if stats := e.RegisterStats(); stats != [16]emulator.RegisterStat{} {
+		t.Fatalf("statistics not reset: %+v", stats)
+	}
+}
+
+func TestRecentInstructions(t *testing.T) {
+	e := run(t,
+		0x60, 0x01, // LD V0, 0x01
+		0x70, 0x01, // ADD V0, 0x01
+		0x12, 0x08, // JP 0x208
+		0x00, 0xe0, // CLS
+		0x61, 0x02, // LD V1, 0x02
+	)
+
+	want := []emulator.TracePoint{
+		{PC: 0x200, Op: 0x6001},
+		{PC: 0x202, Op: 0x7001},
+		{PC: 0x204, Op: 0x1208},
+		{PC: 0x208, Op: 0x6102},
+		{PC: 0x20a, Op: 0x0000},
+	}
+
+	got := e.RecentInstructions()
+
+	if len(got) != len(want) {
+		t.Fatalf("length: got %d, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("instruction %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRecentInstructionsOverflow(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0x70, 0x01, // ADD V0, 0x01
+		0x12, 0x00, // JP 0x200
 	}); err != nil {
 		t.Fatalf("load: %v", err)
 	}
 
-	var steps int
-
-	for {
-		ok, err := e.Step()
-		if err != nil {
+	for range emulator.TraceSize + 1 {
+		if _, err := e.Step(); err != nil {
 			t.Fatalf("step: %v", err)
 		}
-		if !ok {
-			break
-		}
-		steps++
-		if steps%8 == 0 {
-			e.Clock()
-		}
 	}
 
-	if !sound {
-		t.Fatalf("sound timer not triggered")
+	got := e.RecentInstructions()
+
+	if len(got) != emulator.TraceSize {
+		t.Fatalf("length: got %d, want %d", len(got), emulator.TraceSize)
 	}
-}
 
-func TestDelayTimer(t *testing.T) {
-	e := run(t,
-		0x60, 0x0f, // LD V0, 0x0f
-		0xf0, 0x15, // LD DT, V0
-		0xf1, 0x07, // LD V1, DT
-		0x31, 0x00, // SE V1, 0x00
-		0x12, 0x04, // JP 0x204
-	)
+	if want := (emulator.TracePoint{PC: 0x200, Op: 0x7001}); got[len(got)-1] != want {
+		t.Fatalf("newest: got %+v, want %+v", got[len(got)-1], want)
+	}
 
-	check(t, e).
-		register(0x0, 0x0f).
-		register(0x1, 0x00).
-		delayTimer(0x00)
+	if want := (emulator.TracePoint{PC: 0x202, Op: 0x1200}); got[0] != want {
+		t.Fatalf("oldest: got %+v, want %+v", got[0], want)
+	}
 }
 
-func TestSkipOnKeyDown(t *testing.T) {
+func TestRewind(t *testing.T) {
 	e := emulator.New()
 
-	e.KeyDown(0xf)
+	e.SetRewindEnabled(true)
 
 	if err := e.Load([]uint8{
-		0x60, 0x0f, // LD V0, 0x0f
-		0xe0, 0x9e, // SKP V0
-		0x61, 0x01, // LD V1, 0x01
-		0x60, 0x0e, // LD V0, 0x0e
-		0xe0, 0x9e, // SKP V0
-		0x62, 0x01, // LD V2, 0x02
+		0x60, 0x00, // LD V0, 0x00
+		0x70, 0x01, // ADD V0, 0x01
+		0xd0, 0x05, // DRW V0, V0, 0x05
+		0x12, 0x02, // JP 0x202
 	}); err != nil {
 		t.Fatalf("load: %v", err)
 	}
 
-	for {
-		ok, err := e.Step()
-		if err != nil {
+	for range 2 * emulator.TraceSize {
+		if _, err := e.Step(); err != nil {
 			t.Fatalf("step: %v", err)
 		}
-		if !ok {
-			break
+	}
+
+	points := e.RecentInstructions()
+
+	if len(points) != emulator.TraceSize {
+		t.Fatalf("trace size: got %d, want %d", len(points), emulator.TraceSize)
+	}
+
+	// Rewinding to every instruction of the trace, from the newest to the
+	// oldest, restores a state about to execute that instruction.
+
+	var state emulator.State
+
+	for i := len(points) - 1; i >= 0; i-- {
+		if err := e.Rewind(i); err != nil {
+			t.Fatalf("rewind %d: %v", i, err)
+		}
+
+		e.State(&state)
+
+		if state.PC != points[i].PC || state.Instruction() != points[i].Op {
+			t.Fatalf("rewind %d: got %04x at %04x, want %04x at %04x", i, state.Instruction(), state.PC, points[i].Op, points[i].PC)
+		}
+
+		if n := len(e.RecentInstructions()); n != i {
+			t.Fatalf("rewind %d: trace size %d", i, n)
 		}
 	}
 
-	check(t, e).
-		register(0x1, 0x00).
-		register(0x2, 0x01)
+	if err := e.Rewind(0); err == nil {
+		t.Fatal("expected error for empty trace")
+	}
 }
 
-func TestSkipOnKeyNotDown(t *testing.T) {
+func TestRewindMemory(t *testing.T) {
 	e := emulator.New()
 
-	e.KeyDown(0xf)
+	e.SetRewindEnabled(true)
+
+	// The handler of 5xy1 writes the memory directly, without going through
+	// the emulator.
+
+	e.SetOpcodeHandler(0x5001, 0xf00f, func(state *emulator.State, op uint16) {
+		state.Memory[state.I] ^= 0xff
+		state.PC += 2
+	})
 
 	if err := e.Load([]uint8{
-		0x60, 0x0f, // LD V0, 0x0f
-		0xe0, 0xa1, // SKPN V0
-		0x61, 0x01, // LD V1, 0x01
-		0x60, 0x0e, // LD V0, 0x0e
-		0xe0, 0xa1, // SKPN V0
-		0x62, 0x01, // LD V2, 0x02
+		0x60, 0x7b, // LD V0, 0x7b
+		0xa3, 0x00, // LD I, 0x300
+		0xf0, 0x33, // LD B, V0
+		0x70, 0x01, // ADD V0, 0x01
+		0xf1, 0x55, // LD [I], V1
+		0x50, 0x01, // Handler, inverts the byte at I
+		0xd0, 0x13, // DRW V0, V1, 0x03
+		0x12, 0x04, // JP 0x204
 	}); err != nil {
 		t.Fatalf("load: %v", err)
 	}
 
-	for {
-		ok, err := e.Step()
-		if err != nil {
+	// The same bytes are written again and again, so rewinding must undo the
+	// writes of every instruction, from the newest to the oldest. Sprites
+	// loaded between two instructions must be undone too.
+
+	var states []emulator.State
+
+	for i := range 2 * emulator.TraceSize {
+		var state emulator.State
+
+		e.State(&state)
+		states = append(states, state)
+
+		if i == emulator.TraceSize+emulator.TraceSize/2 {
+			if _, err := e.LoadSprites(0x310, [][]uint8{{0x55, 0xaa}}); err != nil {
+				t.Fatalf("load sprites: %v", err)
+			}
+			e.State(&states[i])
+		}
+
+		if _, err := e.Step(); err != nil {
 			t.Fatalf("step: %v", err)
 		}
-		if !ok {
-			break
+	}
+
+	states = states[len(states)-emulator.TraceSize:]
+
+	var state emulator.State
+
+	for i := len(states) - 1; i >= 0; i-- {
+		if err := e.Rewind(i); err != nil {
+			t.Fatalf("rewind %d: %v", i, err)
+		}
+
+		e.State(&state)
+
+		if state != states[i] {
+			t.Fatalf("rewind %d: state differs from the one before the instruction", i)
 		}
 	}
+}
 
-	check(t, e).
-		register(0x1, 0x01).
-		register(0x2, 0x00)
+func TestCPUState(t *testing.T) {
+	e := run(t,
+		0x60, 0x12, // LD V0, 0x12
+		0xa3, 0x45, // LD I, 0x345
+		0x61, 0x09, // LD V1, 0x09
+		0xf1, 0x15, // LD DT, V1
+		0xd0, 0x05, // DRW V0, V0, 0x05
+	)
+
+	var want emulator.State
+
+	e.State(&want)
+
+	// The memory and the display are left unchanged, and every other field is
+	// copied.
+
+	var got emulator.State
+
+	got.Memory[0x345] = 0xff
+	got.Display[0][0] = 0x1
+
+	e.CPUState(&got)
+
+	if got.Memory[0x345] != 0xff || got.Display[0][0] != 0x1 {
+		t.Fatal("memory or display changed")
+	}
+
+	got.Memory = want.Memory
+	got.Display = want.Display
+
+	if got != want {
+		t.Fatal("state differs from the one returned by State")
+	}
 }
 
-func TestSkipWithoutKeyDown(t *testing.T) {
+func TestRewindDisabled(t *testing.T) {
 	e := run(t,
-		0x60, 0x0f, // LD V0, 0x0f
-		0xe0, 0x9e, // SKP V0
-		0x61, 0x01, // LD V1, 0x01
-		0xe0, 0xa1, // SKPN V0
-		0x62, 0x01, // LD V2, 0x01
+		0x60, 0x01, // LD V0, 0x01
 	)
 
-	check(t, e).
-		register(0x1, 0x01).
-		register(0x2, 0x00)
+	if err := e.Rewind(0); err == nil {
+		t.Fatal("expected error with rewind disabled")
+	}
 }
 
-func TestWaitKeyPress(t *testing.T) {
+func TestStepUntil(t *testing.T) {
 	e := emulator.New()
 
 	if err := e.Load([]uint8{
-		0xf0, 0x0a, // LD V0, K
+		0x73, 0x01, // ADD V3, 0x01
+		0x12, 0x00, // JP 0x200
 	}); err != nil {
 		t.Fatalf("load: %v", err)
 	}
 
-	ok, err := e.Step()
-	if err != nil {
-		t.Fatalf("step: %v", err)
+	matched, steps := e.StepUntil(func(s *emulator.State) bool {
+		return s.V[3] == 5
+	}, 100)
+
+	if !matched {
+		t.Fatal("predicate should match")
 	}
-	if !ok {
-		t.Fatal("should continue")
+
+	if steps != 9 {
+		t.Fatalf("steps: got %d, want 9", steps)
 	}
 
 	check(t, e).
-		register(0x0, 0x00)
-
-	e.KeyDown(0xf)
-	e.KeyUp(0xf)
-	e.KeyDown(0x1)
-	e.KeyUp(0x1)
+		register(0x3, 0x05)
 
-	check(t, e).
-		register(0x0, 0x0f)
+	matched, steps = e.StepUntil(func(s *emulator.State) bool {
+		return false
+	}, 10)
 
-	ok, err = e.Step()
-	if err != nil {
-		t.Fatalf("step: %v", err)
+	if matched {
+		t.Fatal("predicate should not match")
 	}
-	if ok {
-		t.Fatal("should stop")
+
+	if steps != 10 {
+		t.Fatalf("steps: got %d, want 10", steps)
 	}
 }
 
-func TestRandom(t *testing.T) {
+func TestStepUntilHalt(t *testing.T) {
 	e := emulator.New()
 
-	e.SetRNG(func() uint32 {
-		return 0x88
-	})
-
 	if err := e.Load([]uint8{
-		0xc0, 0x0f, // RND V0, 0xff
+		0x60, 0x01, // LD V0, 0x01
 	}); err != nil {
 		t.Fatalf("load: %v", err)
 	}
 
-	for {
-		ok, err := e.Step()
-		if err != nil {
-			t.Fatalf("step: %v", err)
-		}
-		if !ok {
-			break
-		}
-	}
+	matched, steps := e.StepUntil(func(s *emulator.State) bool {
+		return false
+	}, 10)
 
-	check(t, e).
-		register(0x0, 0x08)
+	if matched || steps != 1 {
+		t.Fatalf("got matched=%v steps=%d, want matched=false steps=1", matched, steps)
+	}
 }
 
 func TestLoadOversizedProgram(t *testing.T) {
 	e := emulator.New()
 
-	program := make([]uint8, 4096-emulator.ProgramStart+1)
+	program := make([]uint8, len(emulator.Memory{})-emulator.ProgramStart+1)
 
 	if err := e.Load(program); err == nil {
 		t.Fatal("expected error for oversized program")
 	}
 }
 
+func TestLoadSprites(t *testing.T) {
+	e := emulator.New()
+
+	addrs, err := e.LoadSprites(0x300, [][]uint8{
+		{0x80, 0x01},       // Two rows
+		{0xff, 0x81, 0xff}, // Three rows
+	})
+	if err != nil {
+		t.Fatalf("load sprites: %v", err)
+	}
+
+	if len(addrs) != 2 || addrs[0] != 0x300 || addrs[1] != 0x302 {
+		t.Fatalf("addresses: got %x, want [300 302]", addrs)
+	}
+
+	check(t, e).
+		memory(0x300, 0x80).
+		memory(0x301, 0x01).
+		memory(0x302, 0xff).
+		memory(0x303, 0x81).
+		memory(0x304, 0xff)
+}
+
+func TestLoadOversizedSprites(t *testing.T) {
+	e := emulator.New()
+
+	if _, err := e.LoadSprites(0xfffe, [][]uint8{{0x01, 0x02, 0x03}}); err == nil {
+		t.Fatal("expected error for oversized sprites")
+	}
+
+	check(t, e).
+		memory(0xfffe, 0x00).
+		memory(0xffff, 0x00)
+}
+
 func TestStepInvalidOpcode(t *testing.T) {
 	e := emulator.New()
 
@@ -895,6 +2884,76 @@ func (c checks) memory(address uint16, want uint8) checks {
 	return c
 }
 
+// displayMatches checks the active area of the display against art, as
+// described by [matchDisplay].
+func (c checks) displayMatches(art string) checks {
+	c.t.Helper()
+
+	var state emulator.State
+
+	c.e.State(&state)
+
+	matchDisplay(c.t, &state.Display, state.Width, state.Height, art)
+
+	return c
+}
+
+// matchDisplay checks the top-left width×height pixels of display against art,
+// which has a line for every row of pixels starting from the top-left corner,
+// with # for a pixel that is on and . for a pixel that is off. Leading and
+// trailing blank space is ignored on every line, and pixels not covered by art
+// must be off.
+func matchDisplay(t *testing.T, display *emulator.Display, width, height int, art string) {
+	t.Helper()
+
+	var (
+		want     []string
+		artWidth int
+	)
+
+	for _, line := range strings.Split(strings.TrimSpace(art), "\n") {
+		want = append(want, strings.TrimSpace(line))
+		artWidth = max(artWidth, len(want[len(want)-1]))
+	}
+
+	on := func(x, y int) bool {
+		return y < len(want) && x < len(want[y]) && want[y][x] == '#'
+	}
+
+	for y := range height {
+		for x := range width {
+			if (display[y][x] != 0) == on(x, y) {
+				continue
+			}
+
+			// Render the area covered by art, extended to include the pixel
+			// that differs.
+
+			var got strings.Builder
+
+			for gy := range max(len(want), y+1) {
+				for gx := range max(artWidth, x+1) {
+					if display[gy][gx] != 0 {
+						got.WriteByte('#')
+					} else {
+						got.WriteByte('.')
+					}
+				}
+				got.WriteByte('\n')
+			}
+
+			t.Fatalf("display[%d,%d]: pixel should be %s\ngot:\n%swant:\n%s\n", x, y, onOff(on(x, y)), got.String(), strings.Join(want, "\n"))
+		}
+	}
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
 func (c checks) display(x, y int, on bool) checks {
 	c.t.Helper()
 
@@ -928,8 +2987,16 @@ func (c checks) delayTimer(want uint8) checks {
 func run(t *testing.T, data ...uint8) *emulator.Emulator {
 	t.Helper()
 
+	return runQuirks(t, emulator.DefaultQuirks(), data...)
+}
+
+func runQuirks(t *testing.T, quirks emulator.Quirks, data ...uint8) *emulator.Emulator {
+	t.Helper()
+
 	e := emulator.New()
 
+	e.SetQuirks(quirks)
+
 	if err := e.Load(data); err != nil {
 		t.Fatalf("load: %v", err)
 	}
@@ -952,3 +3019,72 @@ func run(t *testing.T, data ...uint8) *emulator.Emulator {
 
 	return e
 }
+
+func BenchmarkDraw(b *testing.B) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0x60, 0x08, // LD V0, 0x08
+		0x61, 0x04, // LD V1, 0x04
+		0xa2, 0x0a, // LD I, 0x20a
+		0xd0, 0x1f, // DRW V0, V1, 0x0f
+		0x12, 0x06, // JP 0x206
+		0xff, 0x81, 0xbd, 0xa5, 0xa5, 0xbd, 0x81, 0xff, // Bitmap
+		0xff, 0x81, 0xbd, 0xa5, 0xa5, 0xbd, 0x81, // Bitmap
+	}); err != nil {
+		b.Fatalf("load: %v", err)
+	}
+
+	for range 3 {
+		if _, err := e.Step(); err != nil {
+			b.Fatalf("step: %v", err)
+		}
+	}
+
+	for b.Loop() {
+		if _, err := e.Step(); err != nil {
+			b.Fatalf("step: %v", err)
+		}
+	}
+}
+
+// BenchmarkFrames runs the flags test rom until it has drawn all of its results,
+// and renders the display after every frame. Unlike BenchmarkDraw, it measures
+// the mix of instructions of a real program, so it can be used to compare
+// different representations of the display. Only the public API is used, so the
+// benchmark runs unchanged against any representation.
+func BenchmarkFrames(b *testing.B) {
+	// The rom draws its last result after about 120 frames, and then loops
+	// forever.
+	const frames = 120
+
+	rom, err := os.ReadFile(filepath.Join("..", "roms", "4-flags.ch8"))
+	if err != nil {
+		b.Fatalf("read rom: %v", err)
+	}
+
+	e := emulator.New()
+
+	if err := e.Load(rom); err != nil {
+		b.Fatalf("load: %v", err)
+	}
+
+	buf := make([]byte, emulator.MaxDisplayWidth*emulator.MaxDisplayHeight/8)
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		e.Reset()
+
+		for frame := range uint64(frames) {
+			if _, err := e.StepFrameWithInput(frame, nil); err != nil {
+				b.Fatalf("frame %d: %v", frame, err)
+			}
+			if _, err := e.DisplayInto(buf); err != nil {
+				b.Fatalf("display: %v", err)
+			}
+		}
+	}
+
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*frames), "ns/frame")
+}
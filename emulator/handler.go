@@ -0,0 +1,69 @@
+package emulator
+
+// OpcodeHandler executes an instruction in place of the emulator. It receives
+// the state of the emulator, which it can modify freely, and the opcode of the
+// instruction.
+type OpcodeHandler func(state *State, op uint16)
+
+type opcodeHandler struct {
+	pattern uint16
+	mask    uint16
+	handler OpcodeHandler
+}
+
+// SetOpcodeHandler installs a handler for the instructions whose opcode op
+// satisfies op&mask == pattern. The handler takes precedence over the built-in
+// implementation of the instruction, if any, and can be used both to override
+// an instruction and to add a new one. If more than one handler matches an
+// instruction, the most recently installed wins.
+//
+// The handler replaces the instruction entirely, and is responsible for
+// advancing the program counter. A handler that doesn't change PC executes the
+// same instruction forever.
+//
+// Installing a handler with the same pattern and mask of an existing one
+// replaces it. Passing a nil handler removes it. Handlers are preserved by
+// [Emulator.Reset].
+func (e *Emulator) SetOpcodeHandler(pattern, mask uint16, handler OpcodeHandler) {
+	pattern &= mask
+
+	for i, h := range e.handlers {
+		if h.pattern == pattern && h.mask == mask {
+			e.handlers = append(e.handlers[:i], e.handlers[i+1:]...)
+			break
+		}
+	}
+
+	if handler != nil {
+		e.handlers = append(e.handlers, opcodeHandler{pattern, mask, handler})
+	}
+}
+
+func (e *Emulator) opcodeHandler(op uint16) OpcodeHandler {
+	for i := len(e.handlers) - 1; i >= 0; i-- {
+		if op&e.handlers[i].mask == e.handlers[i].pattern {
+			return e.handlers[i].handler
+		}
+	}
+	return nil
+}
+
+// runHandler executes op with handler. Handlers change the memory directly,
+// without going through writeMemory, so with rewind enabled their writes are
+// found by comparing the memory before and after the handler.
+func (e *Emulator) runHandler(handler OpcodeHandler, op uint16) {
+	if e.trace.snapshots == nil {
+		handler(&e.state, op)
+		return
+	}
+
+	before := e.state.Memory
+
+	handler(&e.state, op)
+
+	for addr, old := range before {
+		if e.state.Memory[addr] != old {
+			e.trace.recordWrite(uint16(addr), old)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package emulator_test
+
+import (
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestSetOpcodeHandler(t *testing.T) {
+	e := emulator.New()
+
+	// The handler implements 5XY1, unused by CHIP-8, as VX = VX + VY.
+	e.SetOpcodeHandler(0x5001, 0xf00f, func(state *emulator.State, op uint16) {
+		x, y := (op>>8)&0xf, (op>>4)&0xf
+		state.V[x] += state.V[y]
+		state.PC += 2
+	})
+
+	if err := e.Load([]uint8{
+		0x60, 0x03, // LD V0, 0x03
+		0x61, 0x04, // LD V1, 0x04
+		0x50, 0x11, // 5011
+		0x00, 0x00, // HALT
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for {
+		ok, err := e.Step()
+		if err != nil {
+			t.Fatalf("step: %v", err)
+		}
+		if !ok {
+			break
+		}
+	}
+
+	check(t, e).register(0x0, 0x07).register(0x1, 0x04)
+}
+
+func TestSetOpcodeHandlerOverride(t *testing.T) {
+	e := emulator.New()
+
+	e.SetOpcodeHandler(0x6000, 0xf000, func(state *emulator.State, op uint16) {
+		state.V[(op>>8)&0xf] = 0xff
+		state.PC += 2
+	})
+
+	// The most recent handler wins.
+	e.SetOpcodeHandler(0x6100, 0xff00, func(state *emulator.State, op uint16) {
+		state.V[1] = 0xaa
+		state.PC += 2
+	})
+
+	program := []uint8{
+		0x60, 0x01, // LD V0, 0x01
+		0x61, 0x02, // LD V1, 0x02
+	}
+
+	if err := e.Load(program); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for range 2 {
+		if _, err := e.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+	}
+
+	check(t, e).register(0x0, 0xff).register(0x1, 0xaa)
+
+	// Removing the handlers restores the built-in instruction.
+	e.SetOpcodeHandler(0x6000, 0xf000, nil)
+	e.SetOpcodeHandler(0x6100, 0xff00, nil)
+	e.Reset()
+
+	for range 2 {
+		if _, err := e.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+	}
+
+	check(t, e).register(0x0, 0x01).register(0x1, 0x02)
+}
@@ -0,0 +1,76 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// StateHash returns a hash of the state of the emulator. Emulators in the same
+// state have the same hash, which makes it cheap to detect when two emulators
+// running in lockstep diverge.
+//
+// The hash is computed with 64-bit FNV-1a over a fixed big-endian layout of the
+// registers, the stack, the RPL flags, the memory, the active area of the
+// display, the keypad, the selected planes, the audio pattern and its pitch, the
+// frame counters, the quirks, and any wait for a key press, so it is stable
+// across runs and platforms. The random number generator set by
+// [Emulator.SetRNG] is opaque to the emulator, and is not part of the hash.
+func (e *Emulator) StateHash() uint64 {
+	s := &e.state
+
+	b := make([]byte, 0, 2*len(s.Memory))
+
+	b = append(b, s.V[:]...)
+	b = binary.BigEndian.AppendUint16(b, s.I)
+	b = append(b, s.SP, s.DT, s.ST)
+	b = binary.BigEndian.AppendUint16(b, s.PC)
+
+	for _, addr := range s.Stack {
+		b = binary.BigEndian.AppendUint16(b, addr)
+	}
+
+	b = append(b, s.Flags[:]...)
+
+	b = append(b, s.Memory[:]...)
+	b = binary.BigEndian.AppendUint16(b, uint16(s.Width))
+	b = binary.BigEndian.AppendUint16(b, uint16(s.Height))
+
+	for y := range s.Height {
+		b = append(b, s.Display[y][:s.Width]...)
+	}
+
+	for _, pressed := range s.Keys {
+		b = appendBool(b, pressed)
+	}
+
+	b = append(b, s.Planes)
+	b = append(b, s.Pattern[:]...)
+	b = append(b, s.Pitch)
+	b = appendBool(b, s.PatternLoaded)
+
+	b = binary.BigEndian.AppendUint32(b, uint32(s.DrawsThisFrame))
+	b = binary.BigEndian.AppendUint64(b, s.FrameCount)
+
+	b = append(b, uint8(e.quirks.Shift))
+	b = appendBool(b, e.quirks.MemoryIncrementsI)
+	b = appendBool(b, e.quirks.JumpUsesVX)
+	b = appendBool(b, e.quirks.DisplayWait)
+	b = appendBool(b, e.quirks.LargeSprites)
+	b = appendBool(b, e.quirks.LogicResetsVF)
+	b = appendBool(b, e.quirks.WrapSprites)
+	b = append(b, uint8(e.quirks.Scroll))
+
+	b = appendBool(b, e.waitKey)
+	b = append(b, e.waitKeyRegister)
+
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	return h.Sum64()
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
@@ -0,0 +1,52 @@
+package emulator_test
+
+import (
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestStateHash(t *testing.T) {
+	program := []uint8{
+		0x60, 0x01, // LD V0, 0x01
+		0xd0, 0x05, // DRW V0, V0, 0x05
+	}
+
+	a, b := emulator.New(), emulator.New()
+
+	for _, e := range []*emulator.Emulator{a, b} {
+		if err := e.Load(program); err != nil {
+			t.Fatalf("load: %v", err)
+		}
+	}
+
+	if a.StateHash() != b.StateHash() {
+		t.Fatal("hashes differ in the same state")
+	}
+
+	if _, err := a.Step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	if a.StateHash() == b.StateHash() {
+		t.Fatal("hashes equal after a step")
+	}
+
+	if _, err := b.Step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	if a.StateHash() != b.StateHash() {
+		t.Fatal("hashes differ after the same step")
+	}
+}
+
+func TestStateHashQuirks(t *testing.T) {
+	a, b := emulator.New(), emulator.New()
+
+	b.SetQuirks(emulator.VariantSCHIP.Quirks())
+
+	if a.StateHash() == b.StateHash() {
+		t.Fatal("hashes equal with different quirks")
+	}
+}
@@ -0,0 +1,89 @@
+package emulator
+
+import "fmt"
+
+// DefaultStepsPerFrame is the number of instructions executed in every frame by
+// [Emulator.StepFrameWithInput], unless changed with
+// [Emulator.SetStepsPerFrame]. At 60 frames per second, it amounts to 480
+// instructions per second.
+const DefaultStepsPerFrame = 8
+
+// SetStepsPerFrame sets the number of instructions executed in every frame by
+// [Emulator.StepFrameWithInput]. It returns an error if steps is not positive.
+func (e *Emulator) SetStepsPerFrame(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("invalid steps per frame: %d", steps)
+	}
+	e.stepsPerFrame = steps
+	return nil
+}
+
+// StepFrameWithInput runs one frame of the program in lockstep mode. The events
+// are queued as if by [Emulator.QueueKey], then the emulator executes the number
+// of instructions set by [Emulator.SetStepsPerFrame] and advances the timers
+// with [Emulator.Clock], which starts the next frame.
+//
+// Frames must be run in order: frame must be equal to [State.FrameCount], or an
+// error is returned. Two emulators running the same program, with the same
+// quirks and steps per frame, a random number generator set by
+// [Emulator.SetRNG] from the same seed, and the same events for every frame,
+// reach the same state after every frame. This makes it possible to run a
+// program on two machines exchanging only the input of every frame.
+//
+// Like [Emulator.Step], it returns false if the program halted, and an error if
+// an instruction is invalid. In both cases, the frame is not completed.
+func (e *Emulator) StepFrameWithInput(frame uint64, events []KeyEvent) (bool, error) {
+	if frame != e.state.FrameCount {
+		return false, fmt.Errorf("frame %d out of order, expected frame %d", frame, e.state.FrameCount)
+	}
+
+	e.keyEvents = append(e.keyEvents, events...)
+
+	for range e.stepsPerFrame {
+		ok, err := e.Step()
+		if err != nil || !ok {
+			return ok, err
+		}
+	}
+
+	e.Clock()
+
+	return true, nil
+}
+
+// StepFrame runs one frame of the program, like [Emulator.StepFrameWithInput]
+// with no events, and returns whether the sound timer is still active after the
+// frame. An audio backend driven once per frame can use the result to start or
+// stop the buzzer, instead of reacting to every instruction.
+//
+// If the program halts, the rest of the frame is skipped, but the timers still
+// advance, so that the buzzer stops when the sound timer expires. An error is
+// returned if an instruction is invalid, and the frame is not completed.
+func (e *Emulator) StepFrame() (bool, error) {
+	for range e.stepsPerFrame {
+		ok, err := e.Step()
+		if err != nil {
+			return e.state.ST > 0, err
+		}
+		if !ok {
+			break
+		}
+	}
+
+	e.Clock()
+
+	return e.state.ST > 0, nil
+}
+
+// FrameDisplay runs one frame of the program with [Emulator.StepFrame], and
+// returns a copy of the display at the end of the frame. Only the active area of
+// the display, as reported by [Emulator.Resolution], is meaningful. It makes it
+// easy to compare the display of a program with the expected one after a number
+// of frames. It returns an error if an instruction is invalid.
+func (e *Emulator) FrameDisplay() (Display, error) {
+	if _, err := e.StepFrame(); err != nil {
+		return Display{}, err
+	}
+
+	return e.state.Display, nil
+}
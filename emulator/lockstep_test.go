@@ -0,0 +1,152 @@
+package emulator_test
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestLockstep(t *testing.T) {
+	program := []uint8{
+		0xc0, 0x3f, // RND V0, 0x3f
+		0xc1, 0x1f, // RND V1, 0x1f
+		0xf2, 0x29, // LD F, V2
+		0xd0, 0x15, // DRW V0, V1, 0x05
+		0xe2, 0x9e, // SKP V2
+		0x72, 0x01, // ADD V2, 0x01
+		0x12, 0x00, // JP 0x200
+	}
+
+	newEmulator := func() *emulator.Emulator {
+		e := emulator.New()
+		e.SetRNG(rand.New(rand.NewPCG(1, 2)).Uint32)
+		if err := e.Load(program); err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		return e
+	}
+
+	a, b := newEmulator(), newEmulator()
+
+	// The input of every frame only depends on the frame number, like input
+	// exchanged between the two sides of a network session.
+
+	input := func(frame uint64) []emulator.KeyEvent {
+		switch frame % 7 {
+		case 0:
+			return []emulator.KeyEvent{{Down: true, Key: uint8(frame % 16)}}
+		case 3:
+			return []emulator.KeyEvent{{Down: false, Key: uint8((frame - 3) % 16)}}
+		}
+		return nil
+	}
+
+	for frame := range uint64(500) {
+		for _, e := range []*emulator.Emulator{a, b} {
+			if _, err := e.StepFrameWithInput(frame, input(frame)); err != nil {
+				t.Fatalf("frame %d: %v", frame, err)
+			}
+		}
+
+		if a.StateHash() != b.StateHash() {
+			t.Fatalf("frame %d: states differ", frame)
+		}
+	}
+
+	var state emulator.State
+
+	a.State(&state)
+
+	if state.FrameCount != 500 {
+		t.Fatalf("frame count: got %d, want 500", state.FrameCount)
+	}
+}
+
+func TestLockstepOutOfOrder(t *testing.T) {
+	e := emulator.New()
+
+	if _, err := e.StepFrameWithInput(1, nil); err == nil {
+		t.Fatal("expected error for frame out of order")
+	}
+}
+
+func TestInvalidStepsPerFrame(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.SetStepsPerFrame(0); err == nil {
+		t.Fatal("expected error for zero steps per frame")
+	}
+}
+
+func TestStepFrameSound(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0x60, 0x03, // LD V0, 0x03
+		0xf0, 0x18, // LD ST, V0
+		0x00, 0x00, // HALT
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// The sound timer is loaded with 3 in the first frame, and reaches zero at
+	// the end of the third one. The timers keep running after the program halts.
+
+	for frame, want := range []bool{true, true, false, false} {
+		sound, err := e.StepFrame()
+		if err != nil {
+			t.Fatalf("frame %d: %v", frame, err)
+		}
+		if sound != want {
+			t.Fatalf("frame %d: got sound %v, want %v", frame, sound, want)
+		}
+	}
+}
+
+func TestFrameDisplay(t *testing.T) {
+	quirks := emulator.DefaultQuirks()
+	quirks.DisplayWait = true
+
+	e := emulator.New()
+	e.SetQuirks(quirks)
+
+	if err := e.Load([]uint8{
+		0xa0, 0x00, // LD I, 0x000
+		0xd0, 0x15, // DRW V0, V1, 0x05
+		0x70, 0x05, // ADD V0, 0x05
+		0x12, 0x02, // JP 0x202
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// With the display wait, the program draws a 0 in every frame, to the right
+	// of the previous one.
+
+	var displays []emulator.Display
+
+	for range 3 {
+		display, err := e.FrameDisplay()
+		if err != nil {
+			t.Fatalf("frame display: %v", err)
+		}
+
+		displays = append(displays, display)
+	}
+
+	matchDisplay(t, &displays[0], emulator.DisplayWidth, emulator.DisplayHeight, `
+		####
+		#..#
+		#..#
+		#..#
+		####
+	`)
+
+	matchDisplay(t, &displays[2], emulator.DisplayWidth, emulator.DisplayHeight, `
+		####.####.####
+		#..#.#..#.#..#
+		#..#.#..#.#..#
+		#..#.#..#.#..#
+		####.####.####
+	`)
+}
@@ -0,0 +1,265 @@
+package emulator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ShiftMode selects the register shifted by SHR and SHL.
+type ShiftMode int
+
+// Shift modes.
+const (
+	ShiftVIPCopyVY ShiftMode = iota // Copy Vy into Vx, then shift Vx, like the COSMAC VIP.
+	ShiftInPlaceVX                  // Shift Vx in place and ignore Vy, like SUPER-CHIP.
+)
+
+// ScrollMode selects how far SCD, SCU, SCR, and SCL scroll the display in the
+// low resolution.
+type ScrollMode int
+
+// Scroll modes.
+const (
+	ScrollSCHIP11 ScrollMode = iota // Scroll by pixels of the high resolution, so half as far, like SUPER-CHIP 1.1.
+	ScrollSCHIP10                   // Scroll by pixels of the current resolution, like SUPER-CHIP 1.0.
+)
+
+// Quirks selects between behaviors that differ across CHIP-8 interpreters. The
+// zero value doesn't describe any real interpreter: start from [DefaultQuirks]
+// and change the fields that need to differ.
+type Quirks struct {
+	Shift             ShiftMode  // Register shifted by SHR and SHL.
+	MemoryIncrementsI bool       // LD [I], Vx and LD Vx, [I] leave I past the last register.
+	JumpUsesVX        bool       // JP V0, addr is BXNN and jumps to XNN + Vx instead of NNN + V0.
+	DisplayWait       bool       // DRW waits for the next frame if a sprite was already drawn in this one.
+	LargeSprites      bool       // DRW with a height of 0 draws a 16×16 sprite instead of nothing.
+	LogicResetsVF     bool       // OR, AND, and XOR reset VF to 0.
+	WrapSprites       bool       // DRW wraps sprites around the edges of the display instead of clipping them.
+	Scroll            ScrollMode // Distance scrolled in the low resolution.
+}
+
+// DefaultQuirks returns the quirks used by an emulator returned by [New]. These
+// are the quirks of the original COSMAC VIP interpreter, except for the display
+// wait, which slows down most programs considerably.
+func DefaultQuirks() Quirks {
+	return Quirks{
+		Shift:             ShiftVIPCopyVY,
+		MemoryIncrementsI: true,
+		LogicResetsVF:     true,
+		Scroll:            ScrollSCHIP11,
+	}
+}
+
+// Variant identifies a well-known CHIP-8 interpreter.
+type Variant int
+
+// Supported variants.
+const (
+	VariantVIP    Variant = iota // The original interpreter for the COSMAC VIP.
+	VariantCHIP48                // CHIP-48 for the HP-48 calculators.
+	VariantSCHIP                 // SUPER-CHIP 1.1 for the HP-48 calculators.
+	VariantXOCHIP                // XO-CHIP, as implemented by Octo.
+)
+
+var variantNames = []string{
+	VariantVIP:    "vip",
+	VariantCHIP48: "chip48",
+	VariantSCHIP:  "schip",
+	VariantXOCHIP: "xochip",
+}
+
+func (v Variant) String() string {
+	if v < 0 || int(v) >= len(variantNames) {
+		return fmt.Sprintf("unknown (%d)", int(v))
+	}
+	return variantNames[v]
+}
+
+// Quirks returns the quirks of the interpreter identified by v.
+//
+// CHIP-48 increments I by X, rather than X + 1, when storing or loading
+// registers. This can't be expressed by [Quirks], so the CHIP-48 profile
+// leaves I unchanged like SUPER-CHIP does.
+//
+// The SUPER-CHIP profile is SUPER-CHIP 1.1. Apply the scroll-schip10 quirk for
+// SUPER-CHIP 1.0, which scrolls as far in the low resolution as in the high one.
+func (v Variant) Quirks() Quirks {
+	switch v {
+	case VariantCHIP48:
+		return Quirks{
+			Shift:             ShiftInPlaceVX,
+			MemoryIncrementsI: false,
+			JumpUsesVX:        true,
+			DisplayWait:       false,
+			LargeSprites:      false,
+			LogicResetsVF:     false,
+			WrapSprites:       false,
+			Scroll:            ScrollSCHIP11,
+		}
+	case VariantSCHIP:
+		return Quirks{
+			Shift:             ShiftInPlaceVX,
+			MemoryIncrementsI: false,
+			JumpUsesVX:        true,
+			DisplayWait:       false,
+			LargeSprites:      true,
+			LogicResetsVF:     false,
+			WrapSprites:       false,
+			Scroll:            ScrollSCHIP11,
+		}
+	case VariantXOCHIP:
+		return Quirks{
+			Shift:             ShiftVIPCopyVY,
+			MemoryIncrementsI: true,
+			JumpUsesVX:        false,
+			DisplayWait:       false,
+			LargeSprites:      true,
+			LogicResetsVF:     false,
+			WrapSprites:       true,
+			Scroll:            ScrollSCHIP11,
+		}
+	default:
+		return Quirks{
+			Shift:             ShiftVIPCopyVY,
+			MemoryIncrementsI: true,
+			JumpUsesVX:        false,
+			DisplayWait:       true,
+			LargeSprites:      false,
+			LogicResetsVF:     true,
+			WrapSprites:       false,
+			Scroll:            ScrollSCHIP11,
+		}
+	}
+}
+
+// VariantNames returns the names accepted by [ParseVariant].
+func VariantNames() []string {
+	return append([]string(nil), variantNames...)
+}
+
+// ParseVariant returns the variant with the given name, as returned by
+// [VariantNames].
+func ParseVariant(name string) (Variant, error) {
+	for v, n := range variantNames {
+		if n == name {
+			return Variant(v), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown variant %q (valid variants: %s)", name, strings.Join(variantNames, ", "))
+}
+
+// quirkNames maps the names accepted by [ParseQuirks] to the change they apply.
+// Names are kept in a slice, rather than in a map, so that they can be listed in
+// a stable order.
+var quirkNames = []struct {
+	name        string
+	field       string // Field of Quirks changed by the quirk
+	description string
+	apply       func(q *Quirks)
+}{
+	{"shift-vy", "Shift", "SHR and SHL shift Vy into Vx", func(q *Quirks) { q.Shift = ShiftVIPCopyVY }},
+	{"shift-vx", "Shift", "SHR and SHL shift Vx in place", func(q *Quirks) { q.Shift = ShiftInPlaceVX }},
+	{"mem-inc", "MemoryIncrementsI", "LD [I], Vx and LD Vx, [I] increment I", func(q *Quirks) { q.MemoryIncrementsI = true }},
+	{"mem-none", "MemoryIncrementsI", "LD [I], Vx and LD Vx, [I] leave I unchanged", func(q *Quirks) { q.MemoryIncrementsI = false }},
+	{"jump-v0", "JumpUsesVX", "JP V0, addr jumps to NNN + V0", func(q *Quirks) { q.JumpUsesVX = false }},
+	{"jump-vx", "JumpUsesVX", "JP V0, addr jumps to XNN + Vx", func(q *Quirks) { q.JumpUsesVX = true }},
+	{"display-wait", "DisplayWait", "DRW waits for the next frame after a sprite has been drawn", func(q *Quirks) { q.DisplayWait = true }},
+	{"display-nowait", "DisplayWait", "DRW draws immediately", func(q *Quirks) { q.DisplayWait = false }},
+	{"sprite-16", "LargeSprites", "DRW with a height of 0 draws a 16×16 sprite", func(q *Quirks) { q.LargeSprites = true }},
+	{"sprite-none", "LargeSprites", "DRW with a height of 0 draws nothing", func(q *Quirks) { q.LargeSprites = false }},
+	{"logic-reset", "LogicResetsVF", "OR, AND, and XOR reset VF to 0", func(q *Quirks) { q.LogicResetsVF = true }},
+	{"logic-keep", "LogicResetsVF", "OR, AND, and XOR leave VF unchanged", func(q *Quirks) { q.LogicResetsVF = false }},
+	{"sprite-clip", "WrapSprites", "DRW clips sprites at the edges of the display", func(q *Quirks) { q.WrapSprites = false }},
+	{"sprite-wrap", "WrapSprites", "DRW wraps sprites around the edges of the display", func(q *Quirks) { q.WrapSprites = true }},
+	{"scroll-schip11", "Scroll", "Scrolls move half as far in the low resolution", func(q *Quirks) { q.Scroll = ScrollSCHIP11 }},
+	{"scroll-schip10", "Scroll", "Scrolls move as far in the low resolution as in the high one", func(q *Quirks) { q.Scroll = ScrollSCHIP10 }},
+}
+
+// QuirkDescription describes a quirk accepted by [ParseQuirks].
+type QuirkDescription struct {
+	Name        string // Name of the quirk
+	Field       string // Field of Quirks set by the quirk
+	Description string // Behavior selected by the quirk
+	Default     bool   // Is the behavior selected by DefaultQuirks?
+}
+
+// QuirkInfo returns the description of every quirk, in the order returned by
+// [QuirkNames], so that user interfaces don't need to describe them.
+func QuirkInfo() []QuirkDescription {
+	defaults := DefaultQuirks()
+
+	info := make([]QuirkDescription, len(quirkNames))
+
+	for i, q := range quirkNames {
+		probe := defaults
+		q.apply(&probe)
+
+		info[i] = QuirkDescription{
+			Name:        q.name,
+			Field:       q.field,
+			Description: q.description,
+			Default:     probe == defaults,
+		}
+	}
+
+	return info
+}
+
+// QuirkNames returns the names accepted by [ParseQuirks].
+func QuirkNames() []string {
+	names := make([]string, len(quirkNames))
+	for i, q := range quirkNames {
+		names[i] = q.name
+	}
+	return names
+}
+
+// Names returns the names of the quirks in q, one for every behavior, in the
+// order returned by [QuirkNames]. Applying them with [ParseQuirks] to any base
+// returns q.
+func (q Quirks) Names() []string {
+	var names []string
+
+	// Every behavior has a name for each of its values, so a name describes q if
+	// applying it leaves q unchanged.
+
+	for _, quirk := range quirkNames {
+		probe := q
+		quirk.apply(&probe)
+
+		if probe == q {
+			names = append(names, quirk.name)
+		}
+	}
+
+	return names
+}
+
+// ParseQuirks applies a comma-separated list of quirk names, as returned by
+// [QuirkNames], on top of base. Names are applied from left to right, so a later
+// name overrides an earlier one affecting the same behavior. It returns an error
+// if the list contains an unknown name.
+func ParseQuirks(list string, base Quirks) (Quirks, error) {
+	q := base
+
+	if list == "" {
+		return q, nil
+	}
+
+next:
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+
+		for _, quirk := range quirkNames {
+			if quirk.name == name {
+				quirk.apply(&q)
+				continue next
+			}
+		}
+
+		return base, fmt.Errorf("unknown quirk %q (valid quirks: %s)", name, strings.Join(QuirkNames(), ", "))
+	}
+
+	return q, nil
+}
@@ -0,0 +1,639 @@
+package emulator_test
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestParseQuirks(t *testing.T) {
+	got, err := emulator.ParseQuirks("shift-vx,mem-none,display-wait,jump-vx", emulator.DefaultQuirks())
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	want := emulator.Quirks{
+		Shift:             emulator.ShiftInPlaceVX,
+		MemoryIncrementsI: false,
+		JumpUsesVX:        true,
+		DisplayWait:       true,
+		LogicResetsVF:     true,
+	}
+
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseQuirksEmpty(t *testing.T) {
+	got, err := emulator.ParseQuirks("", emulator.DefaultQuirks())
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if want := emulator.DefaultQuirks(); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseQuirksUnknown(t *testing.T) {
+	if _, err := emulator.ParseQuirks("shift-vy,bogus", emulator.DefaultQuirks()); err == nil {
+		t.Fatal("expected error for unknown quirk")
+	}
+}
+
+func TestQuirksRoundTrip(t *testing.T) {
+	e := emulator.New()
+
+	if got, want := e.Quirks(), emulator.DefaultQuirks(); got != want {
+		t.Fatalf("default quirks: got %+v, want %+v", got, want)
+	}
+
+	want := emulator.Quirks{
+		MemoryIncrementsI: true,
+		JumpUsesVX:        true,
+		DisplayWait:       true,
+	}
+
+	e.SetQuirks(want)
+
+	if got := e.Quirks(); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestShiftModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  emulator.ShiftMode
+		op    uint8
+		vx    uint8
+		vy    uint8
+		want  uint8
+		carry uint8
+	}{
+		{"shr copy vy", emulator.ShiftVIPCopyVY, 0x06, 0x04, 0x03, 0x01, 0x01},
+		{"shr in place", emulator.ShiftInPlaceVX, 0x06, 0x04, 0x03, 0x02, 0x00},
+		{"shl copy vy", emulator.ShiftVIPCopyVY, 0x0e, 0x02, 0x81, 0x02, 0x01},
+		{"shl in place", emulator.ShiftInPlaceVX, 0x0e, 0x02, 0x81, 0x04, 0x00},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quirks := emulator.DefaultQuirks()
+			quirks.Shift = tt.mode
+
+			e := runQuirks(t, quirks,
+				0x61, tt.vx, // LD V1, vx
+				0x62, tt.vy, // LD V2, vy
+				0x81, 0x20|tt.op, // SHR/SHL V1, V2
+			)
+
+			check(t, e).
+				register(0x1, tt.want).
+				register(0x2, tt.vy).
+				register(0xf, tt.carry)
+		})
+	}
+}
+
+func TestShiftModesProgram(t *testing.T) {
+	// The program shifts 0x04 right with Vy set to 0x03, and only reaches the
+	// instruction setting V5 if the result is the one it expects.
+
+	program := func(want uint8) []uint8 {
+		return []uint8{
+			0x61, 0x04, // LD V1, 0x04
+			0x62, 0x03, // LD V2, 0x03
+			0x81, 0x26, // SHR V1, V2
+			0x31, want, // SE V1, want
+			0x00, 0x00, // HALT
+			0x65, 0x01, // LD V5, 0x01
+		}
+	}
+
+	tests := []struct {
+		name string
+		mode emulator.ShiftMode
+		want uint8
+		pass uint8
+	}{
+		{"copy vy expecting copy vy", emulator.ShiftVIPCopyVY, 0x01, 0x01},
+		{"copy vy expecting in place", emulator.ShiftVIPCopyVY, 0x02, 0x00},
+		{"in place expecting copy vy", emulator.ShiftInPlaceVX, 0x01, 0x00},
+		{"in place expecting in place", emulator.ShiftInPlaceVX, 0x02, 0x01},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quirks := emulator.DefaultQuirks()
+			quirks.Shift = tt.mode
+
+			check(t, runQuirks(t, quirks, program(tt.want)...)).register(0x5, tt.pass)
+		})
+	}
+}
+
+func TestSetQuirksMidRun(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0x61, 0x04, // LD V1, 0x04
+		0x62, 0x03, // LD V2, 0x03
+		0x81, 0x26, // SHR V1, V2
+		0x83, 0x26, // SHR V3, V2
+		0x00, 0x00, // HALT
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for range 3 {
+		if _, err := e.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+	}
+
+	// The first shift copies V2 into V1. The second one shifts V3 in place,
+	// since the quirk changed in between.
+
+	check(t, e).register(0x1, 0x01)
+
+	quirks := e.Quirks()
+	quirks.Shift = emulator.ShiftInPlaceVX
+	e.SetQuirks(quirks)
+
+	if _, err := e.Step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	check(t, e).register(0x3, 0x00)
+}
+
+func TestShiftModesFlagRegister(t *testing.T) {
+	// When VF is the shifted register, the flag is written after the result in
+	// every mode, so VF holds the shifted-out bit.
+
+	tests := []struct {
+		name string
+		mode emulator.ShiftMode
+		op   uint8
+		vf   uint8
+		vy   uint8
+		want uint8
+	}{
+		{"shr copy vy", emulator.ShiftVIPCopyVY, 0x06, 0x03, 0x02, 0x00},
+		{"shr in place", emulator.ShiftInPlaceVX, 0x06, 0x02, 0x02, 0x00},
+		{"shl copy vy", emulator.ShiftVIPCopyVY, 0x0e, 0x81, 0x40, 0x00},
+		{"shl in place", emulator.ShiftInPlaceVX, 0x0e, 0x81, 0x40, 0x01},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quirks := emulator.DefaultQuirks()
+			quirks.Shift = tt.mode
+
+			e := runQuirks(t, quirks,
+				0x6f, tt.vf, // LD VF, vf
+				0x61, tt.vy, // LD V1, vy
+				0x8f, 0x10|tt.op, // SHR/SHL VF, V1
+			)
+
+			check(t, e).register(0xf, tt.want)
+		})
+	}
+}
+
+func TestLogicResetsVF(t *testing.T) {
+	tests := []struct {
+		name  string
+		reset bool
+		op    uint8
+		want  uint8
+	}{
+		{"or reset", true, 0x01, 0x00},
+		{"or keep", false, 0x01, 0x07},
+		{"and reset", true, 0x02, 0x00},
+		{"and keep", false, 0x02, 0x07},
+		{"xor reset", true, 0x03, 0x00},
+		{"xor keep", false, 0x03, 0x07},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quirks := emulator.DefaultQuirks()
+			quirks.LogicResetsVF = tt.reset
+
+			e := runQuirks(t, quirks,
+				0x61, 0x0c, // LD V1, 0x0c
+				0x62, 0x0a, // LD V2, 0x0a
+				0x6f, 0x07, // LD VF, 0x07
+				0x81, 0x20|tt.op, // OR/AND/XOR V1, V2
+			)
+
+			check(t, e).register(0xf, tt.want)
+		})
+	}
+}
+
+func TestWrapSprites(t *testing.T) {
+	tests := []struct {
+		name string
+		wrap bool
+	}{
+		{"sprite-clip", false},
+		{"sprite-wrap", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quirks := emulator.DefaultQuirks()
+			quirks.WrapSprites = tt.wrap
+
+			// The sprite is two rows of eight pixels, drawn at the bottom right
+			// corner, so that four columns and one row fall outside the display.
+
+			e := runQuirks(t, quirks,
+				0x60, 0x3c, // LD V0, 0x3c
+				0x61, 0x1f, // LD V1, 0x1f
+				0xa2, 0x0a, // LD I, 0x20a
+				0xd0, 0x12, // DRW V0, V1, 0x02
+				0x00, 0x00, // HALT
+				0xff, // Bitmap, ########
+				0xff, // Bitmap, ########
+			)
+
+			check(t, e).
+				display(60, 31, true).
+				display(63, 31, true).
+				display(0, 31, tt.wrap).
+				display(3, 31, tt.wrap).
+				display(4, 31, false).
+				display(60, 0, tt.wrap).
+				display(0, 0, tt.wrap).
+				display(3, 0, tt.wrap).
+				display(0, 1, false)
+		})
+	}
+}
+
+func TestDisplayWaitDrawCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		wait  bool
+		draws int
+	}{
+		{"display-wait", true, 10},
+		{"display-nowait", false, 105},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := emulator.New()
+
+			quirks := emulator.DefaultQuirks()
+			quirks.DisplayWait = tt.wait
+			e.SetQuirks(quirks)
+
+			if err := e.Load([]uint8{
+				0xa2, 0x06, // LD I, 0x206
+				0xd0, 0x01, // DRW V0, V0, 0x01
+				0x12, 0x02, // JP 0x202
+				0x80, // Bitmap, *.......
+			}); err != nil {
+				t.Fatalf("load: %v", err)
+			}
+
+			// Every sprite drawn sets VF, so the flag tracer counts the draws.
+
+			var draws int
+
+			e.SetFlagTracer(func(_ uint16, _ uint8, cause emulator.FlagCause) {
+				if cause == emulator.FlagCollision {
+					draws++
+				}
+			})
+
+			// Every frame runs 21 instructions. Without the display wait, the
+			// 210 instructions are the LD I, 105 DRW, and 104 JP.
+
+			var state emulator.State
+
+			for range 10 {
+				for range 21 {
+					if _, err := e.Step(); err != nil {
+						t.Fatalf("step: %v", err)
+					}
+				}
+
+				// The display wait keeps the program counter at the DRW
+				// until the next frame starts.
+
+				e.State(&state)
+
+				if tt.wait && state.PC != 0x202 {
+					t.Fatalf("pc: got %04x, want 0202", state.PC)
+				}
+
+				e.Clock()
+			}
+
+			if draws != tt.draws {
+				t.Fatalf("draws: got %d, want %d", draws, tt.draws)
+			}
+		})
+	}
+}
+
+func TestMemoryIncrementsI(t *testing.T) {
+	tests := []struct {
+		name      string
+		increment bool
+		op        uint8
+		x         uint8
+		index     uint16
+	}{
+		{"store inc", true, 0x55, 0x0, 0x301},
+		{"store inc v3", true, 0x55, 0x3, 0x304},
+		{"store none", false, 0x55, 0x3, 0x300},
+		{"load inc", true, 0x65, 0x0, 0x301},
+		{"load inc v3", true, 0x65, 0x3, 0x304},
+		{"load none", false, 0x65, 0x3, 0x300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quirks := emulator.DefaultQuirks()
+			quirks.MemoryIncrementsI = tt.increment
+
+			e := runQuirks(t, quirks,
+				0x60, 0x0a, // LD V0, 0x0a
+				0x63, 0x0d, // LD V3, 0x0d
+				0xa3, 0x00, // LD I, 0x300
+				0xf0|tt.x, tt.op, // LD [I], Vx or LD Vx, [I]
+			)
+
+			// Only I depends on the quirk: the registers are transferred from or
+			// to the same addresses in both modes.
+
+			c := check(t, e).index(tt.index)
+
+			if tt.op == 0x55 {
+				c.memory(0x300, 0x0a)
+			} else {
+				c.register(0x0, 0x00)
+			}
+		})
+	}
+}
+
+func TestJumpModes(t *testing.T) {
+	// The instruction jumps to 0x20a + V0 = 0x20c, or to 0x20a + V2 = 0x210 if
+	// X is taken from the address. Each target leaves a different value in V5.
+
+	program := []uint8{
+		0x60, 0x02, // LD V0, 0x02
+		0x62, 0x06, // LD V2, 0x06
+		0xb2, 0x0a, // JP V0, 0x20a
+		0x00, 0x00, // HALT
+		0x00, 0x00, // HALT
+		0x00, 0x00, // HALT
+		0x65, 0x01, // LD V5, 0x01
+		0x00, 0x00, // HALT
+		0x65, 0x02, // LD V5, 0x02
+	}
+
+	tests := []struct {
+		name       string
+		jumpUsesVX bool
+		want       uint8
+	}{
+		{"jump-v0", false, 0x01},
+		{"jump-vx", true, 0x02},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quirks := emulator.DefaultQuirks()
+			quirks.JumpUsesVX = tt.jumpUsesVX
+
+			check(t, runQuirks(t, quirks, program...)).register(0x5, tt.want)
+		})
+	}
+}
+
+func TestScrollModes(t *testing.T) {
+	tests := []struct {
+		name   string
+		scroll emulator.ScrollMode
+		want   string
+	}{
+		{
+			name:   "scroll-schip11",
+			scroll: emulator.ScrollSCHIP11,
+			want: `
+				......
+				......
+				......
+				....##
+				....#.
+			`,
+		},
+		{
+			name:   "scroll-schip10",
+			scroll: emulator.ScrollSCHIP10,
+			want: `
+				......
+				......
+				......
+				......
+				......
+				....##
+				....#.
+			`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quirks := emulator.DefaultQuirks()
+			quirks.Scroll = tt.scroll
+
+			// SCD 3 in the low resolution scrolls by one pixel, like SUPER-CHIP
+			// 1.1, or by three pixels, like SUPER-CHIP 1.0.
+
+			e := runQuirks(t, quirks,
+				0x00, 0xfe, // LOW
+				0x60, 0x04, // LD V0, 0x04
+				0x61, 0x02, // LD V1, 0x02
+				0xa2, 0x0e, // LD I, 0x20e
+				0xd0, 0x12, // DRW V0, V1, 0x02
+				0x00, 0xc3, // SCD 0x3
+				0x00, 0x00, // HALT
+				0xc0, // Bitmap, **......
+				0x80, // Bitmap, *.......
+			)
+
+			check(t, e).displayMatches(tt.want)
+		})
+	}
+}
+
+func TestVariantQuirks(t *testing.T) {
+	// The probe program exercises one quirk at a time and leaves a trace of the
+	// observed behavior in a register or in I:
+	//
+	//   - V3 is 1 if SHR shifts Vy into Vx, or 2 if it shifts Vx in place.
+	//   - I is 0x301 if LD [I], Vx increments I, or 0x300 otherwise.
+	//   - V5 is 1 if JP V0, addr uses V0, or 0 if it uses Vx.
+	//   - V7 is 0 if DRW waits for the next frame, or 1 otherwise.
+
+	probe := []uint8{
+		0x63, 0x04, // LD V3, 0x04
+		0x64, 0x03, // LD V4, 0x03
+		0x83, 0x46, // SHR V3, V4
+		0xa3, 0x00, // LD I, 0x300
+		0xf0, 0x55, // LD [I], V0
+		0x60, 0x00, // LD V0, 0x00
+		0x62, 0x02, // LD V2, 0x02
+		0xb2, 0x12, // JP V0, 0x212
+		0x00, 0x00, // HALT
+		0x65, 0x01, // LD V5, 0x01
+		0xd0, 0x01, // DRW V0, V0, 0x01
+		0xd0, 0x01, // DRW V0, V0, 0x01
+		0x67, 0x01, // LD V7, 0x01
+		0x00, 0x00, // HALT
+	}
+
+	tests := []struct {
+		variant emulator.Variant
+		shift   uint8
+		index   uint16
+		jump    uint8
+		wait    uint8
+	}{
+		{emulator.VariantVIP, 0x01, 0x301, 0x01, 0x00},
+		{emulator.VariantCHIP48, 0x02, 0x300, 0x00, 0x01},
+		{emulator.VariantSCHIP, 0x02, 0x300, 0x00, 0x01},
+		{emulator.VariantXOCHIP, 0x01, 0x301, 0x01, 0x01},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.variant.String(), func(t *testing.T) {
+			e := emulator.New()
+
+			e.SetQuirks(tt.variant.Quirks())
+
+			if err := e.Load(probe); err != nil {
+				t.Fatalf("load: %v", err)
+			}
+
+			// The timers are never clocked, so the probe either halts or waits
+			// forever for the next frame.
+
+			for range 100 {
+				ok, err := e.Step()
+				if err != nil {
+					t.Fatalf("step: %v", err)
+				}
+				if !ok {
+					break
+				}
+			}
+
+			check(t, e).
+				register(0x3, tt.shift).
+				index(tt.index).
+				register(0x5, tt.jump).
+				register(0x7, tt.wait)
+		})
+	}
+}
+
+func TestParseVariant(t *testing.T) {
+	for _, name := range emulator.VariantNames() {
+		v, err := emulator.ParseVariant(name)
+		if err != nil {
+			t.Fatalf("parse %q: %v", name, err)
+		}
+		if got := v.String(); got != name {
+			t.Fatalf("name: got %q, want %q", got, name)
+		}
+	}
+
+	if _, err := emulator.ParseVariant("amiga"); err == nil {
+		t.Fatal("expected error for unknown variant")
+	}
+}
+
+func TestQuirksNames(t *testing.T) {
+	for _, v := range []emulator.Variant{emulator.VariantVIP, emulator.VariantCHIP48, emulator.VariantSCHIP, emulator.VariantXOCHIP} {
+		want := v.Quirks()
+
+		// Start from a base that differs from the variant in every behavior.
+
+		base := emulator.Quirks{
+			Shift:             1 - want.Shift,
+			MemoryIncrementsI: !want.MemoryIncrementsI,
+			JumpUsesVX:        !want.JumpUsesVX,
+			DisplayWait:       !want.DisplayWait,
+			LargeSprites:      !want.LargeSprites,
+			LogicResetsVF:     !want.LogicResetsVF,
+			WrapSprites:       !want.WrapSprites,
+			Scroll:            1 - want.Scroll,
+		}
+
+		got, err := emulator.ParseQuirks(strings.Join(want.Names(), ","), base)
+		if err != nil {
+			t.Fatalf("%v: parse: %v", v, err)
+		}
+		if got != want {
+			t.Fatalf("%v: got %+v, want %+v", v, got, want)
+		}
+	}
+}
+
+func TestQuirkInfo(t *testing.T) {
+	info := emulator.QuirkInfo()
+
+	// Every field of Quirks must be described, with a quirk for every value of
+	// the field.
+
+	fields := reflect.TypeFor[emulator.Quirks]()
+
+	for i := range fields.NumField() {
+		field := fields.Field(i)
+
+		var names []string
+
+		for _, q := range info {
+			if q.Field == field.Name {
+				names = append(names, q.Name)
+			}
+		}
+
+		if len(names) != 2 {
+			t.Errorf("field %s: got quirks %v, want one for each value", field.Name, names)
+		}
+	}
+
+	var defaults []string
+
+	for _, q := range info {
+		if _, ok := fields.FieldByName(q.Field); !ok {
+			t.Errorf("quirk %s: unknown field %s", q.Name, q.Field)
+		}
+		if q.Description == "" {
+			t.Errorf("quirk %s: missing description", q.Name)
+		}
+		if q.Default {
+			defaults = append(defaults, q.Name)
+		}
+	}
+
+	if want := emulator.DefaultQuirks().Names(); !slices.Equal(defaults, want) {
+		t.Errorf("defaults: got %v, want %v", defaults, want)
+	}
+}
@@ -0,0 +1,277 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// saveMagic identifies the format of a state saved by
+// [Emulator.MarshalBinary], followed by a version number.
+const (
+	saveMagic   = "CHIP8SAVE"
+	saveVersion = 8
+)
+
+// MarshalBinary saves the state of the emulator, so that it can be restored by
+// [Emulator.UnmarshalBinary], possibly in a different emulator. Together with
+// the machine state, it saves the configuration that affects the execution of
+// the program: the quirks, the resolution, the instructions per frame of
+// lockstep mode, and the program loaded with [Emulator.Load], which is used by
+// [Emulator.Reset]. The font is part of the memory, so a custom font is saved
+// too.
+//
+// The random number generator set by [Emulator.SetRNG] and the callbacks are
+// opaque to the emulator, and are not saved. Neither are the debugging aids, like
+// the recent instructions and the register statistics.
+func (e *Emulator) MarshalBinary() ([]byte, error) {
+	s := &e.state
+
+	b := make([]byte, 0, 2*len(s.Memory))
+
+	b = append(b, saveMagic...)
+	b = append(b, saveVersion)
+
+	// Configuration.
+
+	b = append(b, uint8(e.quirks.Shift))
+	b = appendBool(b, e.quirks.MemoryIncrementsI)
+	b = appendBool(b, e.quirks.JumpUsesVX)
+	b = appendBool(b, e.quirks.DisplayWait)
+	b = appendBool(b, e.quirks.LargeSprites)
+	b = appendBool(b, e.quirks.LogicResetsVF)
+	b = appendBool(b, e.quirks.WrapSprites)
+	b = append(b, uint8(e.quirks.Scroll))
+	b = binary.BigEndian.AppendUint32(b, uint32(e.stepsPerFrame))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(e.program)))
+	b = append(b, e.program...)
+
+	// Machine state.
+
+	b = append(b, s.V[:]...)
+	b = binary.BigEndian.AppendUint16(b, s.I)
+	b = append(b, s.SP, s.DT, s.ST)
+	b = binary.BigEndian.AppendUint16(b, s.PC)
+
+	for _, addr := range s.Stack {
+		b = binary.BigEndian.AppendUint16(b, addr)
+	}
+
+	b = append(b, s.Flags[:]...)
+
+	b = append(b, s.Memory[:]...)
+	b = binary.BigEndian.AppendUint16(b, uint16(s.Width))
+	b = binary.BigEndian.AppendUint16(b, uint16(s.Height))
+
+	for y := range s.Height {
+		b = append(b, s.Display[y][:s.Width]...)
+	}
+
+	for _, pressed := range s.Keys {
+		b = appendBool(b, pressed)
+	}
+
+	b = append(b, s.Planes)
+	b = append(b, s.Pattern[:]...)
+	b = append(b, s.Pitch)
+	b = appendBool(b, s.PatternLoaded)
+
+	b = binary.BigEndian.AppendUint32(b, uint32(s.DrawsThisFrame))
+	b = binary.BigEndian.AppendUint64(b, s.FrameCount)
+
+	// Execution state.
+
+	b = appendBool(b, e.waitKey)
+	b = append(b, e.waitKeyRegister)
+	b = appendBool(b, e.drawn)
+	b = binary.BigEndian.AppendUint64(b, e.drawFrame)
+	b = appendBool(b, e.halted)
+	b = binary.BigEndian.AppendUint16(b, e.haltPC)
+	b = appendBool(b, e.exited)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(e.keyEvents)))
+
+	for _, event := range e.keyEvents {
+		b = appendBool(b, event.Down)
+		b = append(b, event.Key)
+	}
+
+	return b, nil
+}
+
+// UnmarshalBinary restores a state saved by [Emulator.MarshalBinary], replacing
+// the machine state and the configuration of the emulator. The random number
+// generator and the callbacks of the emulator are left unchanged. If data is not
+// a valid saved state, it returns an error and leaves the emulator unchanged.
+func (e *Emulator) UnmarshalBinary(data []byte) error {
+	r := saveReader{data: data}
+
+	if magic := r.bytes(len(saveMagic)); !bytes.Equal(magic, []byte(saveMagic)) {
+		return errors.New("invalid saved state")
+	}
+
+	if version := r.uint8(); version != saveVersion {
+		return fmt.Errorf("unsupported saved state version %d", version)
+	}
+
+	var (
+		quirks Quirks
+		s      State
+	)
+
+	quirks.Shift = ShiftMode(r.uint8())
+	quirks.MemoryIncrementsI = r.bool()
+	quirks.JumpUsesVX = r.bool()
+	quirks.DisplayWait = r.bool()
+	quirks.LargeSprites = r.bool()
+	quirks.LogicResetsVF = r.bool()
+	quirks.WrapSprites = r.bool()
+	quirks.Scroll = ScrollMode(r.uint8())
+	stepsPerFrame := int(r.uint32())
+	program := bytes.Clone(r.bytes(int(r.uint16())))
+
+	copy(s.V[:], r.bytes(len(s.V)))
+	s.I = r.uint16()
+	s.SP = r.uint8()
+	s.DT = r.uint8()
+	s.ST = r.uint8()
+	s.PC = r.uint16()
+
+	for i := range s.Stack {
+		s.Stack[i] = r.uint16()
+	}
+
+	copy(s.Flags[:], r.bytes(len(s.Flags)))
+
+	copy(s.Memory[:], r.bytes(len(s.Memory)))
+	s.Width = int(r.uint16())
+	s.Height = int(r.uint16())
+
+	if r.err == nil && (s.Width <= 0 || s.Width > MaxDisplayWidth || s.Height <= 0 || s.Height > MaxDisplayHeight) {
+		return fmt.Errorf("invalid saved state: resolution %dx%d", s.Width, s.Height)
+	}
+
+	for y := range s.Height {
+		copy(s.Display[y][:s.Width], r.bytes(s.Width))
+	}
+
+	for i := range s.Keys {
+		s.Keys[i] = r.bool()
+	}
+
+	s.Planes = r.uint8()
+	copy(s.Pattern[:], r.bytes(len(s.Pattern)))
+	s.Pitch = r.uint8()
+	s.PatternLoaded = r.bool()
+
+	s.DrawsThisFrame = int(r.uint32())
+	s.FrameCount = r.uint64()
+
+	waitKey := r.bool()
+	waitKeyRegister := r.uint8()
+	drawn := r.bool()
+	drawFrame := r.uint64()
+	halted := r.bool()
+	haltPC := r.uint16()
+	exited := r.bool()
+
+	var events []KeyEvent
+
+	for range r.uint16() {
+		events = append(events, KeyEvent{Down: r.bool(), Key: r.uint8()})
+	}
+
+	if r.err != nil {
+		return fmt.Errorf("invalid saved state: %v", r.err)
+	}
+
+	if len(r.data) > 0 {
+		return fmt.Errorf("invalid saved state: %d trailing bytes", len(r.data))
+	}
+
+	if quirks.Shift != ShiftVIPCopyVY && quirks.Shift != ShiftInPlaceVX {
+		return fmt.Errorf("invalid saved state: shift mode %d", quirks.Shift)
+	}
+
+	if quirks.Scroll != ScrollSCHIP11 && quirks.Scroll != ScrollSCHIP10 {
+		return fmt.Errorf("invalid saved state: scroll mode %d", quirks.Scroll)
+	}
+
+	if stepsPerFrame <= 0 || len(program) > len(s.Memory)-ProgramStart || int(s.SP) > len(s.Stack) || waitKeyRegister > 0xf || s.Planes > AllPlanes {
+		return errors.New("invalid saved state")
+	}
+
+	e.initialize()
+
+	e.state = s
+	e.quirks = quirks
+	e.stepsPerFrame = stepsPerFrame
+	e.program = program
+	e.waitKey = waitKey
+	e.waitKeyRegister = waitKeyRegister
+	e.drawn = drawn
+	e.drawFrame = drawFrame
+	e.halted = halted
+	e.haltPC = haltPC
+	e.exited = exited
+	e.keyEvents = events
+
+	e.commitFrame()
+
+	return nil
+}
+
+// saveReader decodes a saved state. After the first error, it returns zero
+// values and keeps the error.
+type saveReader struct {
+	data []byte
+	err  error
+}
+
+func (r *saveReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if len(r.data) < n {
+		r.err = errors.New("unexpected end of data")
+		return nil
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+
+	return b
+}
+
+func (r *saveReader) uint8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *saveReader) bool() bool {
+	return r.uint8() != 0
+}
+
+func (r *saveReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *saveReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *saveReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
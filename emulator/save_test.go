@@ -0,0 +1,99 @@
+package emulator_test
+
+import (
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestSaveRestoresConfiguration(t *testing.T) {
+	program := []uint8{
+		0x60, 0x05, // LD V0, 0x05
+		0x61, 0x03, // LD V1, 0x03
+		0x80, 0x16, // SHR V0, V1
+		0xd0, 0x15, // DRW V0, V1, 0x05
+		0xf0, 0x0a, // LD V0, K
+		0x12, 0x00, // JP 0x200
+	}
+
+	quirks := emulator.VariantSCHIP.Quirks()
+	quirks.DisplayWait = true
+
+	saved := emulator.New()
+	saved.SetQuirks(quirks)
+
+	if err := saved.SetStepsPerFrame(3); err != nil {
+		t.Fatalf("set steps per frame: %v", err)
+	}
+
+	if err := saved.Load(program); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if _, err := saved.StepFrameWithInput(0, nil); err != nil {
+		t.Fatalf("frame: %v", err)
+	}
+
+	data, err := saved.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	restored := emulator.New()
+
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if got := restored.Quirks(); got != quirks {
+		t.Fatalf("quirks: got %+v, want %+v", got, quirks)
+	}
+
+	if restored.StateHash() != saved.StateHash() {
+		t.Fatal("hashes differ after restoring the state")
+	}
+
+	// The restored emulator continues like the saved one, including the
+	// instructions per frame, the key press it waits for, and a reset.
+
+	events := []emulator.KeyEvent{{Down: true, Key: 0x7}, {Down: false, Key: 0x7}}
+
+	for _, e := range []*emulator.Emulator{saved, restored} {
+		for frame := range uint64(4) {
+			if _, err := e.StepFrameWithInput(frame+1, events); err != nil {
+				t.Fatalf("frame: %v", err)
+			}
+		}
+	}
+
+	if restored.StateHash() != saved.StateHash() {
+		t.Fatal("hashes differ after running the restored state")
+	}
+
+	saved.Reset()
+	restored.Reset()
+
+	if restored.StateHash() != saved.StateHash() {
+		t.Fatal("hashes differ after a reset")
+	}
+}
+
+func TestRestoreInvalidState(t *testing.T) {
+	e := emulator.New()
+
+	data, err := e.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	for _, invalid := range [][]byte{
+		nil,
+		[]byte("CHIP8SAVE"),
+		data[:len(data)-1],
+		append(data, 0),
+	} {
+		if err := e.UnmarshalBinary(invalid); err == nil {
+			t.Errorf("expected an error for %d bytes", len(invalid))
+		}
+	}
+}
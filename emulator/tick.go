@@ -0,0 +1,57 @@
+package emulator
+
+import "time"
+
+// FramePeriod is the duration of a frame. The timers of the CHIP-8 are
+// decremented at 60 Hz, once per frame.
+const FramePeriod = time.Second / 60
+
+// Tick advances the wall clock of the emulator by d, and calls [Emulator.Clock]
+// once for every [FramePeriod] elapsed. The time left over is accumulated and
+// carried over to the next call, so that the timers run at 60 Hz on average even
+// if Tick is called at irregular intervals. It returns the number of frames
+// started, which is zero if less than a frame has elapsed.
+func (e *Emulator) Tick(d time.Duration) int {
+	if d > 0 {
+		e.tickBacklog += d
+	}
+
+	frames := int(e.tickBacklog / FramePeriod)
+
+	e.tickBacklog -= time.Duration(frames) * FramePeriod
+
+	for range frames {
+		e.Clock()
+	}
+
+	return frames
+}
+
+// TimerKind identifies the event of a timer reaching zero.
+type TimerKind int
+
+// Timer events.
+const (
+	TimerNone  TimerKind = iota // No timer is running.
+	TimerSound                  // The sound timer expires, and the sound stops.
+	TimerDelay                  // The delay timer reaches zero.
+)
+
+// NextTimerEvent returns the number of calls to [Emulator.Clock] until the next
+// timer reaches zero, and which timer it is. If both timers reach zero in the
+// same tick, the sound timer is reported. A runner with nothing else to do can
+// sleep for that many frames, unless the program changes the timers in the
+// meantime. If no timer is running, or the timers are disabled with
+// [Emulator.SetTimersEnabled], it returns 0 and [TimerNone].
+func (e *Emulator) NextTimerEvent() (int, TimerKind) {
+	st, dt := int(e.state.ST), int(e.state.DT)
+
+	switch {
+	case e.timersPaused || st == 0 && dt == 0:
+		return 0, TimerNone
+	case st > 0 && (dt == 0 || st <= dt):
+		return st, TimerSound
+	default:
+		return dt, TimerDelay
+	}
+}
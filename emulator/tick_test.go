@@ -0,0 +1,93 @@
+package emulator_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+func TestTick(t *testing.T) {
+	e := emulator.New()
+
+	if err := e.Load([]uint8{
+		0x60, 0xff, // LD V0, 0xff
+		0xf0, 0x15, // LD DT, V0
+	}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	for range 2 {
+		if _, err := e.Step(); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+	}
+
+	// Irregular frame times, from a fraction of a frame to several frames, add up
+	// to exactly one second.
+
+	durations := []time.Duration{
+		5 * time.Millisecond,
+		16 * time.Millisecond,
+		40 * time.Millisecond,
+		time.Millisecond,
+		100 * time.Millisecond,
+		17 * time.Millisecond,
+		321 * time.Millisecond,
+		500 * time.Millisecond,
+	}
+
+	var frames int
+
+	for _, d := range durations {
+		frames += e.Tick(d)
+	}
+
+	if frames != 60 {
+		t.Fatalf("frames: got %d, want 60", frames)
+	}
+
+	check(t, e).
+		delayTimer(0xff - 60)
+
+	if n := e.Tick(0); n != 0 {
+		t.Fatalf("frames without elapsed time: got %d, want 0", n)
+	}
+}
+
+func TestNextTimerEvent(t *testing.T) {
+	e := run(t,
+		0x60, 0x05, // LD V0, 0x05
+		0xf0, 0x15, // LD DT, V0
+		0x61, 0x03, // LD V1, 0x03
+		0xf1, 0x18, // LD ST, V1
+	)
+
+	checkEvent := func(wantTicks int, wantKind emulator.TimerKind) {
+		t.Helper()
+
+		if ticks, kind := e.NextTimerEvent(); ticks != wantTicks || kind != wantKind {
+			t.Fatalf("next event: got %d %v, want %d %v", ticks, kind, wantTicks, wantKind)
+		}
+	}
+
+	checkEvent(3, emulator.TimerSound)
+
+	// No event is coming while the timers are disabled.
+
+	e.SetTimersEnabled(false)
+	checkEvent(0, emulator.TimerNone)
+	e.SetTimersEnabled(true)
+
+	for range 3 {
+		e.Clock()
+	}
+
+	checkEvent(2, emulator.TimerDelay)
+
+	for range 2 {
+		e.Clock()
+	}
+
+	checkEvent(0, emulator.TimerNone)
+}
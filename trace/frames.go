@@ -0,0 +1,177 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// CaptureFrames runs frames frames of the program loaded in e in lockstep mode,
+// without any input, and returns the display at the end of every frame in the
+// reference format. The capture stops early if the program halts.
+func CaptureFrames(e *emulator.Emulator, frames int) ([]byte, error) {
+	var (
+		b     bytes.Buffer
+		state emulator.State
+	)
+
+	pixels := make([]byte, emulator.MaxDisplayWidth*emulator.MaxDisplayHeight/8)
+
+	for range frames {
+		e.State(&state)
+
+		frame := state.FrameCount
+
+		ok, err := e.StepFrameWithInput(frame, nil)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %v", frame, err)
+		}
+		if !ok {
+			break
+		}
+
+		n, err := e.DisplayInto(pixels)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %v", frame, err)
+		}
+
+		width, height := e.Resolution()
+
+		fmt.Fprintf(&b, "frame %d %dx%d %x\n", frame, width, height, pixels[:n])
+	}
+
+	return b.Bytes(), nil
+}
+
+// Reference holds the frames of a reference interpreter.
+type Reference struct {
+	frames map[uint64]referenceFrame
+}
+
+type referenceFrame struct {
+	width, height int
+	pixels        []byte
+}
+
+// ParseReference parses frames in the reference format.
+func ParseReference(data []byte) (*Reference, error) {
+	r := Reference{frames: make(map[uint64]referenceFrame)}
+
+	var (
+		line int
+		last uint64
+	)
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+
+	for s.Scan() {
+		line++
+
+		text := s.Text()
+
+		if text == "" || text[0] == '#' {
+			continue
+		}
+
+		var (
+			frame     uint64
+			f         referenceFrame
+			hexPixels string
+		)
+
+		if _, err := fmt.Sscanf(text, "frame %d %dx%d %s", &frame, &f.width, &f.height, &hexPixels); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		if len(r.frames) > 0 && frame <= last {
+			return nil, fmt.Errorf("line %d: frame %d out of order", line, frame)
+		}
+
+		if f.width <= 0 || f.width > emulator.MaxDisplayWidth || f.height <= 0 || f.height > emulator.MaxDisplayHeight {
+			return nil, fmt.Errorf("line %d: invalid resolution %dx%d", line, f.width, f.height)
+		}
+
+		pixels, err := hex.DecodeString(hexPixels)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		if want := (f.width*f.height + 7) / 8; len(pixels) != want {
+			return nil, fmt.Errorf("line %d: %d bytes of pixels, want %d", line, len(pixels), want)
+		}
+
+		f.pixels = pixels
+		r.frames[frame] = f
+		last = frame
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %v", line+1, err)
+	}
+
+	return &r, nil
+}
+
+// Check compares the display at the end of frame, packed like
+// [emulator.Emulator.DisplayInto], with the reference. It returns an error
+// describing the first difference, or nil if the displays are equal or the
+// reference doesn't contain the frame.
+func (r *Reference) Check(frame uint64, width, height int, pixels []byte) error {
+	f, ok := r.frames[frame]
+	if !ok {
+		return nil
+	}
+
+	if width != f.width || height != f.height {
+		return fmt.Errorf("frame %d: resolution %dx%d, want %dx%d", frame, width, height, f.width, f.height)
+	}
+
+	for i := range width * height {
+		mask := byte(0x80) >> (i % 8)
+
+		if pixels[i/8]&mask != f.pixels[i/8]&mask {
+			return fmt.Errorf("frame %d: pixel (%d, %d) differs", frame, i%width, i/width)
+		}
+	}
+
+	return nil
+}
+
+// FirstDivergence runs frames frames of the program loaded in e in lockstep
+// mode, without any input, and compares the display at the end of every frame
+// with the reference. It returns an error describing the first difference, or
+// nil if there is none. The comparison stops early if the program halts.
+func FirstDivergence(e *emulator.Emulator, r *Reference, frames int) error {
+	var state emulator.State
+
+	pixels := make([]byte, emulator.MaxDisplayWidth*emulator.MaxDisplayHeight/8)
+
+	for range frames {
+		e.State(&state)
+
+		frame := state.FrameCount
+
+		ok, err := e.StepFrameWithInput(frame, nil)
+		if err != nil {
+			return fmt.Errorf("frame %d: %v", frame, err)
+		}
+		if !ok {
+			break
+		}
+
+		if _, err := e.DisplayInto(pixels); err != nil {
+			return fmt.Errorf("frame %d: %v", frame, err)
+		}
+
+		width, height := e.Resolution()
+
+		if err := r.Check(frame, width, height, pixels); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package trace_test
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+	"github.com/francescomari/chip-8/trace"
+)
+
+func TestFirstDivergence(t *testing.T) {
+	program := []uint8{
+		0xd0, 0x05, // DRW V0, V0, 0x05
+		0x12, 0x02, // JP 0x202
+	}
+
+	// The program draws the digit 0 at (0, 0) in the first frame. The reference
+	// agrees, except for an extra pixel at (3, 7) in frame 2.
+
+	digit := make([]byte, emulator.DisplayWidth*emulator.DisplayHeight/8)
+
+	for i, row := range []byte{0xf0, 0x90, 0x90, 0x90, 0xf0} {
+		digit[i*8] = row
+	}
+
+	extra := bytes.Clone(digit)
+	extra[(7*emulator.DisplayWidth+3)/8] |= 0x80 >> 3
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "# Synthetic reference\n")
+	fmt.Fprintf(&b, "frame 0 64x32 %x\n", digit)
+	fmt.Fprintf(&b, "frame 2 64x32 %x\n", extra)
+	fmt.Fprintf(&b, "frame 3 64x32 %x\n", digit)
+
+	ref, err := trace.ParseReference(b.Bytes())
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	err = trace.FirstDivergence(loadProgram(t, emulator.DefaultQuirks(), program), ref, 10)
+	if err == nil {
+		t.Fatal("expected a divergence")
+	}
+
+	if want := "frame 2: pixel (3, 7) differs"; err.Error() != want {
+		t.Fatalf("divergence: got %q, want %q", err, want)
+	}
+
+	if err := trace.FirstDivergence(loadProgram(t, emulator.DefaultQuirks(), program), ref, 2); err != nil {
+		t.Fatalf("divergence before frame 2: %v", err)
+	}
+}
+
+func TestCaptureFrames(t *testing.T) {
+	path := filepath.Join("..", "roms", "2-ibm-logo.ch8")
+
+	frames, err := trace.CaptureFrames(load(t, path), 20)
+	if err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+
+	ref, err := trace.ParseReference(frames)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if err := trace.FirstDivergence(load(t, path), ref, 20); err != nil {
+		t.Fatalf("divergence: %v", err)
+	}
+
+	// A different speed changes the frame in which every part of the logo is
+	// drawn.
+
+	e := load(t, path)
+
+	if err := e.SetStepsPerFrame(2); err != nil {
+		t.Fatalf("set steps per frame: %v", err)
+	}
+
+	if err := trace.FirstDivergence(e, ref, 20); err == nil {
+		t.Fatal("expected a divergence")
+	}
+}
+
+func TestParseInvalidReference(t *testing.T) {
+	for _, data := range []string{
+		"frame x 64x32 00\n",
+		"frame 0 0x32 00\n",
+		"frame 0 8x1 0000\n",
+		"frame 0 8x1 zz\n",
+		"frame 1 8x1 00\nframe 1 8x1 00\n",
+	} {
+		if _, err := trace.ParseReference([]byte(data)); err == nil {
+			t.Errorf("expected an error for %q", data)
+		}
+	}
+}
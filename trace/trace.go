@@ -0,0 +1,88 @@
+// Package trace captures execution traces of CHIP-8 programs, to detect
+// changes in the behavior of the emulator.
+//
+// A trace has one line for every executed instruction, with the address and the
+// opcode of the instruction followed by the registers after its execution:
+//
+//	0200 6001 v=01000000000000000000000000000000 i=0000 sp=00 dt=00 st=00
+//
+// The format only depends on the machine state, so a trace captured from a
+// reference program can be stored in a golden file and compared with a trace
+// captured by a later version of the emulator.
+//
+// The package also compares the display with the frames of a reference
+// interpreter, stored in a text format with one line for every frame:
+//
+//	frame 12 64x32 f0000000...
+//
+// The line holds the number of the frame, counting from 0, the resolution of
+// the display, and the pixels of the display at the end of the frame in
+// hexadecimal. Pixels are packed one bit per pixel, in row-major order, with the
+// leftmost pixel of every byte in its most significant bit, like
+// [emulator.Emulator.DisplayInto]. Frames must be listed in increasing order,
+// but can be skipped. Empty lines and lines starting with # are ignored, so that
+// the format is easy to produce from other emulators.
+package trace
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/francescomari/chip-8/emulator"
+)
+
+// CaptureTrace executes at most steps instructions of the program loaded in e
+// and returns their trace. The capture stops early if the program halts. If an
+// instruction fails, the error is recorded as the last line of the trace.
+func CaptureTrace(e *emulator.Emulator, steps int) []byte {
+	var (
+		b     bytes.Buffer
+		state emulator.State
+	)
+
+	for range steps {
+		e.State(&state)
+
+		pc, op := state.PC, state.Instruction()
+
+		ok, err := e.Step()
+		if err != nil {
+			fmt.Fprintf(&b, "%04x %04x error: %v\n", pc, op, err)
+			break
+		}
+		if !ok {
+			break
+		}
+
+		e.State(&state)
+
+		fmt.Fprintf(&b, "%04x %04x v=%x i=%04x sp=%02x dt=%02x st=%02x\n", pc, op, state.V[:], state.I, state.SP, state.DT, state.ST)
+	}
+
+	return b.Bytes()
+}
+
+// Compare returns an error describing the first difference between the traces
+// want and got, or nil if they are equal.
+func Compare(want, got []byte) error {
+	wantLines, gotLines := lines(want), lines(got)
+
+	for i := range min(len(wantLines), len(gotLines)) {
+		if !bytes.Equal(wantLines[i], gotLines[i]) {
+			return fmt.Errorf("step %d: got %q, want %q", i, gotLines[i], wantLines[i])
+		}
+	}
+
+	if len(wantLines) != len(gotLines) {
+		return fmt.Errorf("trace length: got %d steps, want %d", len(gotLines), len(wantLines))
+	}
+
+	return nil
+}
+
+func lines(trace []byte) [][]byte {
+	if len(trace) == 0 {
+		return nil
+	}
+	return bytes.Split(bytes.TrimSuffix(trace, []byte("\n")), []byte("\n"))
+}
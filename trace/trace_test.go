@@ -0,0 +1,93 @@
+package trace_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/francescomari/chip-8/emulator"
+	"github.com/francescomari/chip-8/trace"
+)
+
+var update = flag.Bool("update", false, "Update the golden files")
+
+// goldenSteps is enough for the reference program to execute all of its tests
+// and reach the final loop.
+const goldenSteps = 310
+
+func TestGoldenTrace(t *testing.T) {
+	e := load(t, filepath.Join("..", "roms", "3-corax+.ch8"))
+
+	got := trace.CaptureTrace(e, goldenSteps)
+
+	golden := filepath.Join("testdata", "corax.golden")
+
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatalf("write golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+
+	if err := trace.Compare(want, got); err != nil {
+		t.Fatalf("trace changed: %v", err)
+	}
+}
+
+func TestCompareDetectsDifferences(t *testing.T) {
+	program := []uint8{
+		0x60, 0x81, // LD V0, 0x81
+		0x61, 0x02, // LD V1, 0x02
+		0x80, 0x16, // SHR V0, V1
+	}
+
+	want := trace.CaptureTrace(loadProgram(t, emulator.DefaultQuirks(), program), 10)
+
+	if err := trace.Compare(want, trace.CaptureTrace(loadProgram(t, emulator.DefaultQuirks(), program), 10)); err != nil {
+		t.Fatalf("same behavior: %v", err)
+	}
+
+	// Changing the shift quirk changes the behavior of the shift handler, which
+	// stands for a regression in the implementation of the instruction.
+
+	quirks := emulator.DefaultQuirks()
+	quirks.Shift = emulator.ShiftInPlaceVX
+
+	if err := trace.Compare(want, trace.CaptureTrace(loadProgram(t, quirks, program), 10)); err == nil {
+		t.Fatal("expected a difference in the trace")
+	}
+
+	if err := trace.Compare(want, trace.CaptureTrace(loadProgram(t, emulator.DefaultQuirks(), program), 2)); err == nil {
+		t.Fatal("expected a difference in the length of the trace")
+	}
+}
+
+func load(t *testing.T, path string) *emulator.Emulator {
+	t.Helper()
+
+	program, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read program: %v", err)
+	}
+
+	return loadProgram(t, emulator.DefaultQuirks(), program)
+}
+
+func loadProgram(t *testing.T, quirks emulator.Quirks, program []uint8) *emulator.Emulator {
+	t.Helper()
+
+	e := emulator.New()
+
+	e.SetQuirks(quirks)
+
+	if err := e.Load(program); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	return e
+}